@@ -1,656 +1,125 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
-)
-
-func findObjectIndex(name string, files []string) (int, bool) {
-	// returns the index of name
-	for i, f := range files {
-		if f == name {
-			return i, true
-		}
-	}
-	return 0, false
-}
-
-func TestGenerateADGroupName(t *testing.T) {
-	i := expectedInput{Environment: "boogie", ProjectName: "extra-good"}
-	got := generateADGroupNames(&i)
-	want := "RES-BOOGIE-OPSH-DEVELOPER-EXTRA_GOOD"
-	if want != got["EDIT"] {
-		t.Errorf("wanted %s, but got %s: \n", want, got)
-	}
-	want = "RES-BOOGIE-OPSH-VIEWER-EXTRA_GOOD"
-	if want != got["VIEW"] {
-		t.Errorf("wanted %s, but got %s: \n", want, got)
-	}
-}
-
-func TestCheckInputValid(t *testing.T) {
-	data := []byte(`{
-		"projectname": "nic-test-backbase-reference",
-		"role": "developer",
-		"environment": "dev",
-		"optionals":[
-					{
-						"name":"cpu",
-						"count": 1
-					},
-					{
-						"name":"memory",
-						"count":1,
-						"unit":"Gi"
-					},
-					{
-						"name":"volumes",
-						"count":2
-					}
-		]
-	}`)
-	d := expectedInput{}
-	err := json.Unmarshal(data, &d)
-	if err != nil {
-		t.Errorf("wanted %s, but got %s: \n", "nil", err.Error())
-	}
-
-	if d.Environment != "dev" {
-		t.Errorf("wanted %s, but got %s: \n", "dev", d.Environment)
-	}
-
-	if d.Optionals == nil {
-		t.Errorf("wanted %s, but got %s: \n", "optionals", "nil")
-	}
-
-	// complain about spaces
-	badData := []byte(`{
-		"projectname": "nic-test backbase-reference",
-		"environment": "dev",
-		"optionals":[
-					{
-						"name":"cpu",
-						"count": 1
-					},
-					{
-						"name":"memory",
-						"count":1,
-						"unit":"Gi"
-					},
-					{
-						"name":"volumes",
-						"count":2
-					}
-		]
-	}`)
-	d = expectedInput{}
-	err = json.Unmarshal(badData, &d)
-	if err == nil {
-		t.Errorf("wanted %s, but got %s: \n", "an error", "nil")
-	}
-	want := "data contains illegal spaces"
-	got := err.Error()
-	if got != want {
-		t.Errorf("wanted %v, but got %v: \n", want, got)
-	}
-
-	// complain about underscores
-	badData = []byte(`{
-		"projectname": "nic_test-backbase-reference",
-		"environment": "dev",
-		"optionals":[
-					{
-						"name":"cpu",
-						"count": 1
-					},
-					{
-						"name":"memory",
-						"count":1,
-						"unit":"Gi"
-					},
-					{
-						"name":"volumes",
-						"count":2
-					}
-		]
-	}`)
-	d = expectedInput{}
-	err = json.Unmarshal(badData, &d)
-	if err == nil {
-		t.Errorf("wanted %s, but got %s: \n", "an error", "nil")
-	}
-	want = "data contains illegal underscores"
-	got = err.Error()
-	if got != want {
-		t.Errorf("wanted %v, but got %v: \n", want, got)
-	}
-
-	// should autoformat the data
-	badData = []byte(`{
-		"projectname": "NIC-test-backbase-reference",
-		"environment": "DEV",
-		"optionals":[
-					{
-						"name":"cpu",
-						"count": 1
-					},
-					{
-						"name":"memory",
-						"count":1,
-						"unit":"Gi"
-					},
-					{
-						"name":"volumes",
-						"count":2
-					}
-		]
-	}`)
-	d = expectedInput{}
-	err = json.Unmarshal(badData, &d)
-	if err != nil {
-		t.Errorf("wanted %s, but got %s: \n", "nil", err.Error())
-	}
-
-	if d.ProjectName != "nic-test-backbase-reference" {
-		t.Errorf("wanted %v, but got %v: \n", "nic-test-backbase-reference", d.ProjectName)
-	}
-
-	if d.Environment != "dev" {
-		t.Errorf("wanted %v, but got %v: \n", "dev", d.Environment)
-	}
-
-	// should complain about invalid name in optionals
-	badData = []byte(`{
-		"projectname": "NIC-test-backbase-reference",
-		"environment": "DEV",
-		"optionals":[
-					{
-						"name":"cpu",
-						"count": 1
-					},
-					{
-						"name":"memooory",
-						"count":1,
-						"unit":"Gi"
-					},
-					{
-						"name":"volumes",
-						"count":2
-					}
-		]
-	}`)
-	d = expectedInput{}
-	err = json.Unmarshal(badData, &d)
-	if err == nil {
-		t.Errorf("wanted %s, but got %s: \n", "an error", "nil")
-	}
-	if err.Error() != "optional name entry is invalid: memooory" {
-		t.Errorf("wanted %s, but got %s: \n", "an error", err.Error())
-	}
-
-	// should complain about invalid unit in optionals
-	badData = []byte(`{
-		"projectname": "NIC-test-backbase-reference",
-		"environment": "DEV",
-		"optionals":[
-					{
-						"name":"cpu",
-						"count": 1
-					},
-					{
-						"name":"memory",
-						"count":1,
-						"unit":"Giz"
-					},
-					{
-						"name":"volumes",
-						"count":2
-					}
-		]
-	}`)
-	d = expectedInput{}
-	err = json.Unmarshal(badData, &d)
-	if err == nil {
-		t.Errorf("wanted %s, but got %s: \n", "an error", "nil")
-	}
-	if err.Error() != "optional unit entry is invalid: Giz" {
-		t.Errorf("wanted %s, but got %s: \n", "optional unit entry is invalid: Giz", err.Error())
-	}
-
-	// should complain about missing unit in optionals
-	badData = []byte(`{
-		"projectname": "NIC-test-backbase-reference",
-		"environment": "DEV",
-		"optionals":[
-					{
-						"name":"cpu",
-						"count": 1
-					},
-					{
-						"name":"storage",
-						"count":1
-					},
-					{
-						"name":"volumes",
-						"count":2
-					}
-		]
-	}`)
-	d = expectedInput{}
-	err = json.Unmarshal(badData, &d)
-	if err == nil {
-		t.Errorf("wanted %s, but got %s: \n", "an error", "nil")
-	}
-	if err.Error() != "invalid or missing unit for: storage" {
-		t.Errorf("wanted %s, but got %s: \n", "invalid or missing unit for: storage", err.Error())
-	}
-
-	// should complain about invalid count in optionals with type error
-	badData = []byte(`{
-		"projectname": "NIC-test-backbase-reference",
-		"environment": "DEV",
-		"optionals":[
-					{
-						"name":"cpu",
-						"count": 1
-					},
-					{
-						"name":"memory",
-						"count":1.1,
-						"unit":"Gi"
-					},
-					{
-						"name":"volumes",
-						"count":2
-					}
-		]
-	}`)
-	d = expectedInput{}
-	err = json.Unmarshal(badData, &d)
-	if err == nil {
-		t.Errorf("wanted %s, but got %s: \n", "an error", "nil")
-	}
-	if err.Error() != "json: cannot unmarshal number 1.1 into Go struct field optionalObject.Optionals.count of type int" {
-		t.Errorf("wanted %s, but got %s: \n", "json: cannot unmarshal number 1.1 into Go struct field optionalObject.Optionals.count of type int", err.Error())
-	}
-
-	// should complain about invalid count in optionals with type error
-	badData = []byte(`{
-		"projectname": "NIC-test-backbase-reference",
-		"environment": "DEV",
-		"optionals":[
-					{
-						"name":"cpu",
-						"count": 1
-					},
-					{
-						"name":"memory",
-						"count":"1",
-						"unit":"Gi"
-					},
-					{
-						"name":"volumes",
-						"count":2
-					}
-		]
-	}`)
-	d = expectedInput{}
-	err = json.Unmarshal(badData, &d)
-	if err == nil {
-		t.Errorf("wanted %s, but got %s: \n", "an error", "nil")
-	}
-	if err.Error() != "json: cannot unmarshal string into Go struct field optionalObject.Optionals.count of type int" {
-		t.Errorf("wanted %s, but got %s: \n", "json: cannot unmarshal string into Go struct field optionalObject.Optionals.count of type int", err.Error())
-	}
-
-	// should complain about invalid count in optionals with type error
-	data = []byte(`{
-		"projectname": "NIC-test-backbase-reference",
-		"environment": "DEV",
-		"optionals":[
-					{
-						"name":"cpu",
-						"count": 1000,
-						"unit": "m"
-					},
-					{
-						"name":"memory",
-						"count":1,
-						"unit":"Gi"
-					},
-					{
-						"name":"volumes",
-						"count":2
-					}
-		]
-	}`)
-	d = expectedInput{}
-	err = json.Unmarshal(data, &d)
-	if err != nil {
-		t.Errorf("wanted %s, but got %s: \n", "nil", err.Error())
-	}
-	if d.Optionals[0].Count.int != 1000 || d.Optionals[0].Unit.string != "m" {
-		t.Errorf("wanted %s, but got %s: \n", "should be equal", "are not equal")
-	}
-
-}
-
-func TestValidUnit(t *testing.T) {
-
-	want := false
-	got := validUnit("gb")
-	if got != want {
-		t.Errorf("wanted %v, but got %v: \n", want, got)
-	}
-
-	want = true
-	got = validUnit("Mi")
-	if got != want {
-		t.Errorf("wanted %v, but got %v: \n", want, got)
-	}
-}
-
-func TestValidName(t *testing.T) {
-	want := false
-	got := validName("cpus")
-	if got != want {
-		t.Errorf("wanted %v, but got %v: \n", want, got)
-	}
-
-	want = true
-	got = validName("cpu")
-	if got != want {
-		t.Errorf("wanted %v, but got %v: \n", want, got)
-	}
-
-	want = false
-	got = validName("Memory")
-	if got != want {
-		t.Errorf("wanted %v, but got %v: \n", want, got)
-	}
-
-	want = true
-	got = validName("volumes")
-	if got != want {
-		t.Errorf("wanted %v, but got %v: \n", want, got)
-	}
-
-	want = true
-	got = validName("storage")
-	if got != want {
-		t.Errorf("wanted %v, but got %v: \n", want, got)
-	}
-
-	want = false
-	got = validName("disk")
-	if got != want {
-		t.Errorf("wanted %v, but got %v: \n", want, got)
-	}
-}
-
-func TestCreateNewProjectObject(t *testing.T) {
-
-	expectedBytes := []byte(`{"kind":"Project","apiVersion":"project.openshift.io/v1","metadata":{"name":"boogie-test"}}`)
 
-	i := expectedInput{ProjectName: "boogie-test"}
+	"github.com/nicgrobler/parser/provisioner"
+)
 
-	fileName, baseObject := createProjectObject(&i)
-	gotBytes, err := json.Marshal(baseObject)
+func TestWriteResults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parser-test-")
 	if err != nil {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
-	}
-	if string(expectedBytes) != string(gotBytes) {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes, gotBytes)
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
 	}
-	expectedObjectName := projectFilename
-	if expectedObjectName != fileName {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedObjectName, fileName)
-	}
-
-}
-
-func TestCreateNewNetworkPolicyObject(t *testing.T) {
-
-	expectedBytes := []byte(`{"kind":"NetworkPolicy","apiVersion":"networking.k8s.io/v1","metadata":{"name":"deny-by-default","namespace":"boogie-test"},"spec":{"podSelector":{},"policyTypes":["Ingress","Egress"]}}`)
+	defer os.RemoveAll(dir)
 
-	i := expectedInput{ProjectName: "boogie-test"}
-	fileName, baseObject := createNetworkPolicyObject(&i)
-	gotBytes, err := json.Marshal(baseObject)
+	i := provisioner.Request{ProjectName: "boogie-test", Environment: "dev"}
+	results, err := provisioner.Generate(context.Background(), i)
 	if err != nil {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
-	}
-	if string(expectedBytes) != string(gotBytes) {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes, gotBytes)
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
 	}
-	expectedObjectName := networkPolicyFilename
-	if expectedObjectName != fileName {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedObjectName, fileName)
-	}
-
-}
-
-func TestCreateNewEgressNetworkPolicyObject(t *testing.T) {
-
-	expectedBytes := []byte(`{"kind":"EgressNetworkPolicy","apiVersion":"network.openshift.io/v1","metadata":{"name":"default-egress","namespace":"boogie-test"},"spec":{"egress":[{"type":"Deny","to":{"cidrSelector":"0.0.0.0/0"}}]}}`)
 
-	i := expectedInput{ProjectName: "boogie-test"}
-
-	fileName, baseObject := createEgressNetworkPolicyObject(&i)
-	gotBytes, err := json.Marshal(baseObject)
+	err = writeResults(results, dir, i.Environment, i.ProjectName, provisioner.FormatJSON, false)
 	if err != nil {
 		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
 	}
-	if string(expectedBytes) != string(gotBytes) {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes, gotBytes)
-	}
-	expectedObjectName := egressNetworkPolicyFilename
-	if expectedObjectName != fileName {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedObjectName, fileName)
-	}
-
-}
-
-func TestCreateNewRoleBindingObject(t *testing.T) {
-
-	expectedBytes := make(map[string][]byte)
-
-	expectedBytes[editRolebindingFilename] = []byte(`{"kind":"RoleBinding","apiVersion":"rbac.authorization.k8s.io/v1","metadata":{"name":"boogie-test-edit-binding","namespace":"boogie-test"},"subjects":[{"kind":"Group","apiGroup":"rbac.authorization.k8s.io","name":"RES-DEV-OPSH-DEVELOPER-BOOGIE_TEST"}],"roleRef":{"kind":"ClusterRole","apiGroup":"rbac.authorization.k8s.io","name":"edit"}}`)
-	expectedBytes[viewRolebindingFilename] = []byte(`{"kind":"RoleBinding","apiVersion":"rbac.authorization.k8s.io/v1","metadata":{"name":"boogie-test-view-binding","namespace":"boogie-test"},"subjects":[{"kind":"Group","apiGroup":"rbac.authorization.k8s.io","name":"RES-DEV-OPSH-VIEWER-BOOGIE_TEST"}],"roleRef":{"kind":"ClusterRole","apiGroup":"rbac.authorization.k8s.io","name":"view"}}`)
-	expectedBytes[jenkinsRolebindinngFilename] = []byte(`{"kind":"RoleBinding","apiVersion":"rbac.authorization.k8s.io/v1","metadata":{"name":"boogie-test-admin-relman-binding","namespace":"boogie-test"},"subjects":[{"kind":"ServiceAccount","name":"relman","namespace":"relman"}],"roleRef":{"kind":"ClusterRole","apiGroup":"rbac.authorization.k8s.io","name":"admin"}}`)
-
-	i := expectedInput{ProjectName: "boogie-test", Environment: "dev"}
-
-	fileNames, baseObject := createRoleBindingObjects(&i)
-	expectedObjectName := editRolebindingFilename
-
-	index, found := findObjectIndex(expectedObjectName, fileNames)
-	// verify file is in list
-	if !found {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", "true", "false")
-	}
-	// verify contents are correct
-	gotBytes, err := json.Marshal(baseObject[index])
-	if err != nil {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
-	}
-	if string(expectedBytes[expectedObjectName]) != string(gotBytes) {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes[expectedObjectName], gotBytes)
+	for _, entry := range results {
+		gotBytes, err := ioutil.ReadFile(filepath.Join(dir, i.Environment, i.ProjectName, entry.Name))
+		if err != nil {
+			t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+			continue
+		}
+		expectedBytes, _ := json.MarshalIndent(entry.Content, "", "  ")
+		if string(expectedBytes)+"\n" != string(gotBytes) {
+			t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes, gotBytes)
+		}
 	}
 
-	expectedObjectName = viewRolebindingFilename
-	index, found = findObjectIndex(expectedObjectName, fileNames)
-	// verify file is in list
-	if !found {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", "true", "false")
+	// should refuse to clobber the existing project
+	err = writeResults(results, dir, i.Environment, i.ProjectName, provisioner.FormatJSON, false)
+	if err == nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "an error", "nil")
 	}
 
-	gotBytes, err = json.Marshal(baseObject[index])
+	// unless forced, in which case stale files are replaced along with the rest of the directory
+	stale := filepath.Join(dir, i.Environment, i.ProjectName, "stale.yaml")
+	ioutil.WriteFile(stale, []byte("stale"), 0644)
+	err = writeResults(results, dir, i.Environment, i.ProjectName, provisioner.FormatJSON, true)
 	if err != nil {
 		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
 	}
-	if string(expectedBytes[expectedObjectName]) != string(gotBytes) {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes[expectedObjectName], gotBytes)
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", "stale file removed", err)
 	}
 
-	expectedObjectName = jenkinsRolebindinngFilename
-	index, found = findObjectIndex(expectedObjectName, fileNames)
-	// verify file is in list
-	if !found {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", "true", "false")
-	}
-	gotBytes, err = json.Marshal(baseObject[index])
-	if err != nil {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	// a failing run must not leave anything behind
+	bad := provisioner.Results{provisioner.Entry{Name: "1-project.yaml", Content: "ok"}, provisioner.Entry{Name: "../escape.yaml", Content: "bad"}}
+	err = writeResults(bad, dir, i.Environment, "other-project", provisioner.FormatJSON, false)
+	if err == nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "an error", "nil")
 	}
-	if string(expectedBytes[expectedObjectName]) != string(gotBytes) {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes[expectedObjectName], gotBytes)
+	leftovers, _ := ioutil.ReadDir(filepath.Join(dir, i.Environment))
+	if len(leftovers) != 1 || leftovers[0].Name() != i.ProjectName {
+		t.Errorf("wanted \n%s, \nbut got \n%d entries \n", "only the first project", len(leftovers))
 	}
-
 }
 
-func TestCreateNewLimitsObject(t *testing.T) {
-	expectedBytes := []byte(`{"kind":"ResourceQuota","apiVersion":"v1","metadata":{"name":"default-quotas","namespace":"boogie-test"},"spec":{"hard":{"limits.cpu":2,"limits.memory":"1Gi","persistentvolumeclaims":3,"requests.storage":"100Gi"}}}`)
-
-	o := []optionalObject{
-		optionalObject{
-			Name:  oName{"cpu"},
-			Count: oCount{2},
-		},
-		optionalObject{
-			Name:  oName{"memory"},
-			Count: oCount{1},
-			Unit:  oUnit{"Gi"},
-		},
-		optionalObject{
-			Name:  oName{"volumes"},
-			Count: oCount{3},
-		},
-		optionalObject{
-			Name:  oName{"storage"},
-			Count: oCount{100},
-			Unit:  oUnit{"Gi"},
-		},
-	}
-
-	i := expectedInput{ProjectName: "boogie-test", Environment: "dev", Optionals: o}
-
-	fileName, baseObject := createLimitsObject(&i)
-	// verify contents are correct
-	gotBytes, err := json.Marshal(baseObject)
-	if err != nil {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
-	}
-	if string(expectedBytes) != string(gotBytes) {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes, gotBytes)
-	}
-	expectedObjectName := quotaFilename
-	if expectedObjectName != fileName {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedObjectName, fileName)
-	}
-
-	expectedBytes = []byte(`{"kind":"ResourceQuota","apiVersion":"v1","metadata":{"name":"default-quotas","namespace":"boogie-test"},"spec":{"hard":{"limits.cpu":1,"limits.memory":"5Gi","requests.storage":"5Gi"}}}`)
-
-	o = []optionalObject{
-		optionalObject{
-			Name:  oName{"cpu"},
-			Count: oCount{1},
-		},
-		optionalObject{
-			Name:  oName{"memory"},
-			Count: oCount{5},
-			Unit:  oUnit{"Gi"},
-		},
-		optionalObject{
-			Name:  oName{"storage"},
-			Count: oCount{5},
-			Unit:  oUnit{"Gi"},
-		}}
-
-	i = expectedInput{ProjectName: "boogie-test", Environment: "dev", Optionals: o}
-
-	fileName, baseObject = createLimitsObject(&i)
-	// verify contents are correct
-	gotBytes, err = json.Marshal(baseObject)
-	if err != nil {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
-	}
-	if string(expectedBytes) != string(gotBytes) {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes, gotBytes)
-	}
-
-	expectedObjectName = quotaFilename
-	if expectedObjectName != fileName {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedObjectName, fileName)
-	}
-
-	expectedBytes = []byte(`{"kind":"","apiVersion":"","metadata":{"name":""},"spec":{"hard":{}}}`)
-
-	i = expectedInput{ProjectName: "boogie-test", Environment: "dev"}
-
-	fileName, baseObject = createLimitsObject(&i)
-	// verify contents are correct
-	gotBytes, err = json.Marshal(baseObject)
-	if err != nil {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
-	}
-	if string(expectedBytes) != string(gotBytes) {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes, gotBytes)
-	}
-	expectedObjectName = ""
-	if expectedObjectName != fileName {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedObjectName, fileName)
+func TestBatchSummary(t *testing.T) {
+	batch := []provisioner.BatchResult{
+		provisioner.BatchResult{Index: 1, ProjectName: "team-a"},
+		provisioner.BatchResult{Index: 2, Err: errors.New("data contains illegal spaces")},
+		provisioner.BatchResult{Index: 3, ProjectName: "team-a", Err: errors.New("duplicate projectname for environment dev, already requested by entry 1")},
+		provisioner.BatchResult{Index: 4, ProjectName: "team-c", FromSize: []string{"cpu: 2", "memory: 4Gi"},
+			Violations: []provisioner.PolicyViolation{provisioner.PolicyViolation{Key: "pods", Requested: "300", Allowed: "200"}}},
+	}
+	summary, ok := batchSummary(batch)
+	want := `request 2 (unknown project) failed: data contains illegal spaces
+request 3 (team-a) failed: duplicate projectname for environment dev, already requested by entry 1
+request 4 (team-c) size supplied: cpu: 2, memory: 4Gi
+request 4 (team-c) ceilings exceeded: pods requested 300, allowed 200
+2 of 4 projects generated, 2 failed`
+	if ok || summary != want {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", want, summary)
+	}
+
+	summary, ok = batchSummary(batch[:1])
+	want = "1 of 1 projects generated, 0 failed"
+	if !ok || summary != want {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", want, summary)
 	}
 }
 
-func TestIsEmptyObject(t *testing.T) {
-	q := quota{}
-	isEmpty := isEmptyObject(q)
-	if !isEmpty {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", "empty", "not empty")
+func TestInputArgs(t *testing.T) {
+	valid := []struct {
+		args []string
+		file string
+		want string
+	}{
+		{nil, "", ""},
+		{nil, "request.json", "request.json"},
+		{[]string{"-"}, "", "-"},
+	}
+	for _, v := range valid {
+		got, err := inputArgs(v.args, v.file)
+		if err != nil || got != v.want {
+			t.Errorf("%v: wanted \n%s, \nbut got \n%s %v \n", v.args, v.want, got, err)
+		}
 	}
 
-	q = quota{}
-	q.Kind = "whatever"
-
-	isEmpty = isEmptyObject(q)
-	if isEmpty {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", "not empty", "empty")
-	}
-}
-
-func TestCreateNewLimitsObjectCPU(t *testing.T) {
-	expectedBytes := []byte(`{"kind":"ResourceQuota","apiVersion":"v1","metadata":{"name":"default-quotas","namespace":"boogie-test"},"spec":{"hard":{"limits.cpu":"200m","limits.memory":"1Gi","persistentvolumeclaims":3}}}`)
-
-	o := []optionalObject{
-		optionalObject{
-			Name:  oName{"cpu"},
-			Count: oCount{200},
-			Unit:  oUnit{"m"},
-		},
-		optionalObject{
-			Name:  oName{"memory"},
-			Count: oCount{1},
-			Unit:  oUnit{"Gi"},
-		},
-		optionalObject{
-			Name:  oName{"volumes"},
-			Count: oCount{3},
-		}}
-
-	i := expectedInput{ProjectName: "boogie-test", Environment: "dev", Optionals: o}
-
-	fileName, baseObject := createLimitsObject(&i)
-	// verify contents are correct
-	gotBytes, err := json.Marshal(baseObject)
-	if err != nil {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
-	}
-	if string(expectedBytes) != string(gotBytes) {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes, gotBytes)
+	// flags after "-" are not parsed, and nothing else is expected
+	invalid := [][]string{{"-", "-format", "yaml"}, {"request.json"}, {"-", "-"}}
+	for _, args := range invalid {
+		if _, err := inputArgs(args, ""); err == nil {
+			t.Errorf("%v: wanted \n%s, \nbut got \n%s \n", args, "an error", "nil")
+		}
 	}
-	expectedObjectName := quotaFilename
-	if expectedObjectName != fileName {
-		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedObjectName, fileName)
+	if _, err := inputArgs([]string{"-"}, "request.json"); err == nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "an error", "nil")
 	}
-
 }
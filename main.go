@@ -1,432 +1,266 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"strconv"
 	"strings"
-)
-
-/*
-	This parser takes a basic input (what will eventually come from Helpline request / elsewhere) in json, and uses this, along with some basic
-    logic (based on standards) to produce two json files:
-    1. New project json
-	2. roleBinding json for EDIT Active Directory group to this new project*
-	3. roleBinding json for VIEW Active Directory group to this new project*
-	4. roleBinding json for relman service account to this new project
-	5. resource limit json
-	6. networkPolicy for the project
-	7. egress networkPolicy for the project
-
-    *The AD group names are generated using the logic used to create the groups within active directory.
-*/
 
-const (
-	quotaFilename               string = "10-quotas.yaml"
-	projectFilename             string = "1-project.yaml"
-	defaultRolebindingFilename  string = "10-default-rolebinding.yaml"
-	jenkinsRolebindinngFilename string = "10-jenkins-rolebinding.yaml"
-	editRolebindingFilename     string = "10-edit-group-rolebinding.yaml"
-	viewRolebindingFilename     string = "10-view-group-rolebinding.yaml"
-	networkPolicyFilename       string = "10-networkpolicy.yaml"
-	egressNetworkPolicyFilename string = "10-egress-networkpolicy.yaml"
+	"github.com/nicgrobler/parser/provisioner"
 )
 
 /*
-	Composable minimal types used to create new json files.
+	Command line wrapper around the provisioner package. It takes a basic input (what will eventually come from
+	Helpline request / elsewhere) in json or yaml, and produces the OpenShift objects for the project, either on
+	STDOUT or as files on disk.
 */
 
-type metaData struct {
-	Name      string `json:"name"`                // binding name
-	NameSpace string `json:"namespace,omitempty"` // projectname
-}
-
-type roleRef struct {
-	Kind     string `json:"kind"`     // Project
-	APIGroup string `json:"apiGroup"` // rbac.authorization.k8s.io
-	Name     string `json:"name"`     // group name
-
-}
-
-type specQuota struct {
-	Hard struct {
-		CPU     interface{} `json:"limits.cpu,omitempty"`
-		Memory  string      `json:"limits.memory,omitempty"`
-		PVC     int         `json:"persistentvolumeclaims,omitempty"`
-		Storage string      `json:"requests.storage,omitempty"`
-	} `json:"hard,omitempty"`
-}
-
-type specNetwork struct {
-	PodSelector struct {
-		Todo string `json:"not-implemented-yet,omitempty"`
-	} `json:"podSelector,omitempty"`
-	PolicyTypes []string `json:"policyTypes,omitempty"`
-}
-
-type specEgressNetwork struct {
-	Egress []egressRules `json:"egress"`
-}
-
-type egressRules struct {
-	EgressType string `json:"type"`
-	To         struct {
-		Cidr string `json:"cidrSelector,omitempty"`
-		URL  string `json:"dnsName,omitempty"`
-	} `json:"to"`
-}
-
-type subject struct {
-	Kind      string `json:"kind"`                // Project
-	APIGroup  string `json:"apiGroup,omitempty"`  // rbac.authorization.k8s.io
-	Name      string `json:"name"`                // group name
-	Namespace string `json:"namespace,omitempty"` // name of project
-
-}
-
-type subjects []subject
-
-type baseObject struct {
-	Kind       string   `json:"kind"`       // Project
-	APIVersion string   `json:"apiVersion"` // project.openshift.io/v1
-	Metadata   metaData `json:"metadata"`
-}
-
-type roleBinding struct {
-	Kind       string   `json:"kind"`       // RoleBinding
-	APIVersion string   `json:"apiVersion"` // rbac.authorization.k8s.io/v1
-	Metadata   metaData `json:"metadata"`
-	Subjects   subjects `json:"subjects"`
-	RoleRef    roleRef  `json:"roleRef"`
-}
+func runBatch(p *provisioner.Provisioner, requests []json.RawMessage, outDir string, format string, force bool, errorFormat string) {
+	/*
+		every request is processed, even when some of them fail. Results of the successful ones are written out
+		as usual, and a summary of successes and failures goes to STDERR so as not to mix with the results. With
+		-errors json, the failures are described by a json document there instead.
+	*/
+	batch := p.GenerateBatch(context.Background(), requests)
 
-type quota struct {
-	Kind       string    `json:"kind"`       // RoleBinding
-	APIVersion string    `json:"apiVersion"` // rbac.authorization.k8s.io/v1
-	Metadata   metaData  `json:"metadata"`
-	Spec       specQuota `json:"spec"`
-}
+	if outDir != "" {
+		for i := range batch {
+			if batch[i].Err == nil {
+				batch[i].Err = writeResults(batch[i].Results, outDir, batch[i].Environment, batch[i].ProjectName, format, force)
+			}
+		}
+	} else {
+		data, err := provisioner.MarshalBatch(batch, format)
+		if err != nil {
+			exitLog("serialization error: " + err.Error())
+		}
+		fmt.Print(string(data))
+	}
 
-type network struct {
-	Kind       string      `json:"kind"`       // NetworkPolicy
-	APIVersion string      `json:"apiVersion"` // networking.k8s.io/v1
-	Metadata   metaData    `json:"metadata"`
-	Spec       specNetwork `json:"spec"`
+	summary, ok := batchSummary(batch)
+	if errorFormat == "json" {
+		summary = string(provisioner.BatchErrorDocument(batch))
+	}
+	fmt.Fprintln(os.Stderr, summary)
+	if !ok {
+		os.Exit(1)
+	}
 }
 
-type egressNetwork struct {
-	Kind       string            `json:"kind"`       // EgressNetworkPolicy
-	APIVersion string            `json:"apiVersion"` // network.openshift.io/v1
-	Metadata   metaData          `json:"metadata"`
-	Spec       specEgressNetwork `json:"spec"`
+func batchSummary(batch []provisioner.BatchResult) (string, bool) {
+	// returns a human readable summary, and whether every request succeeded
+	var lines []string
+	failed := 0
+	for _, result := range batch {
+		if result.Err == nil {
+			if len(result.FromSize) > 0 {
+				lines = append(lines, "request "+strconv.Itoa(result.Index)+" ("+result.ProjectName+") size supplied: "+strings.Join(result.FromSize, ", "))
+			}
+			if len(result.Violations) > 0 {
+				lines = append(lines, "request "+strconv.Itoa(result.Index)+" ("+result.ProjectName+") ceilings exceeded: "+policySummary(result.Violations))
+			}
+			continue
+		}
+		failed++
+		name := result.ProjectName
+		if name == "" {
+			name = "unknown project"
+		}
+		lines = append(lines, "request "+strconv.Itoa(result.Index)+" ("+name+") failed: "+result.Err.Error())
+	}
+	lines = append(lines, strconv.Itoa(len(batch)-failed)+" of "+strconv.Itoa(len(batch))+" projects generated, "+strconv.Itoa(failed)+" failed")
+	return strings.Join(lines, "\n"), failed == 0
 }
 
-/*
-	Results object: a simple nested json, where each key is the name of the destinationn file,
-	and the data, is the associated value
-*/
-
-type resultEntry struct {
-	Name    string      `json:"filename"`
-	Content interface{} `json:"content"`
+func policySummary(violations []provisioner.PolicyViolation) string {
+	var s []string
+	for _, violation := range violations {
+		s = append(s, violation.String())
+	}
+	return strings.Join(s, "; ")
 }
 
-type resultsObject []resultEntry
-
-/*
-	Main functions for creating our serialized json objects
-*/
-
-func createProjectObject(data *expectedInput) (string, baseObject) {
-	// create our object
-	y := baseObject{
-		Kind:       "Project",
-		APIVersion: "project.openshift.io/v1",
+func inputArgs(args []string, file string) (string, error) {
+	/*
+		a lone "-" argument is shorthand for "-file -". Flags stop being parsed at the first argument, so it has
+		to come after all of them, and anything else is a mistake rather than something to ignore
+	*/
+	switch {
+	case len(args) == 0:
+		return file, nil
+	case len(args) == 1 && args[0] == "-" && file == "":
+		return "-", nil
 	}
-	y.Metadata.Name = data.ProjectName
-
-	name := projectFilename
-	return name, y
+	return file, errors.New("unexpected arguments: " + strings.Join(args, " ") + "\nusage: parser [flags] [-], where \"-\" reads the payload from STDIN and must follow all flags")
 }
 
-func createNetworkPolicyObject(data *expectedInput) (string, network) {
-
-	// create our NetworkPolicy object
-	y := network{
-		Kind:       "NetworkPolicy",
-		APIVersion: "networking.k8s.io/v1",
-	}
-	y.Metadata.Name = "deny-by-default"
-	y.Metadata.NameSpace = data.ProjectName
-	y.Spec.PolicyTypes = []string{
-		"Ingress",
-		"Egress",
+func readInput(data string, file string) ([]byte, error) {
+	/*
+		the payload comes from exactly one of: the -data flag, a file, or STDIN
+	*/
+	switch {
+	case data != "" && file != "":
+		return nil, errors.New("only one of -data and -file may be supplied")
+	case data != "":
+		return []byte(data), nil
+	case file == "-":
+		return ioutil.ReadAll(os.Stdin)
+	case file != "":
+		return ioutil.ReadFile(file)
+	default:
+		return nil, errors.New("missing input")
 	}
+}
 
-	name := networkPolicyFilename
-
-	return name, y
-
+func logFunction(format string) {
+	fmt.Println(format)
+	os.Exit(1)
 }
 
-func createEgressNetworkPolicyObject(data *expectedInput) (string, egressNetwork) {
+var exitLog = logFunction
 
-	// create our EgressNetworkPolicy object
-	e := egressNetwork{
-		Kind:       "EgressNetworkPolicy",
-		APIVersion: "network.openshift.io/v1",
+func requestError(msg string, err error, errorFormat string) {
+	/*
+		describes a request that could not be decoded or generated, either as text, or as a json document listing
+		every problem for tools that display them to whoever made the request. As with batches, the document goes
+		to STDERR, leaving STDOUT for generated objects only
+	*/
+	if errorFormat == "json" {
+		fmt.Fprintln(os.Stderr, string(provisioner.ErrorDocument(err)))
+		os.Exit(1)
 	}
-	e.Metadata.Name = "default-egress"
-	e.Metadata.NameSpace = data.ProjectName
-	e.Spec.Egress = []egressRules{egressRules{EgressType: "Deny"}}
-	e.Spec.Egress[0].To.Cidr = "0.0.0.0/0"
-
-	name := egressNetworkPolicyFilename
-
-	return name, e
-
+	exitLog(msg + err.Error())
 }
 
-func createRoleBindingObjects(data *expectedInput) ([]string, []roleBinding) {
+func runSchema(args []string) {
 	/*
-		This function will produce the data for 3 files:
-
-		1. The generated AD groupname that has the EDIT role
-		2. The generated AD groupname that has the VIEW role
-		3. The static service account name (relman) that has admin role for deployments
+		prints the JSON Schema for requests, under the given config, so that forms and other tools can check
+		requests before they are sent
 	*/
-	var names []string
-	var bytes []roleBinding
-
-	// first generate data for 1 & 2 above
-	adRolesAndGroupNames := generateADGroupNames(data)
-	for roleName, adGroupName := range adRolesAndGroupNames {
-		roleBindingName := strings.ToLower(data.ProjectName + "-" + roleName + "-" + "binding")
-		// create our object
-		y := roleBinding{
-			Kind:       "RoleBinding",
-			APIVersion: "rbac.authorization.k8s.io/v1",
-		}
-		y.Metadata.Name = roleBindingName
-		y.Metadata.NameSpace = data.ProjectName
-		y.Subjects = subjects{
-			subject{
-				Kind:     "Group",
-				APIGroup: "rbac.authorization.k8s.io",
-				Name:     adGroupName,
-			},
-		}
-		y.RoleRef.APIGroup = "rbac.authorization.k8s.io"
-		y.RoleRef.Kind = "ClusterRole"
-		y.RoleRef.Name = strings.ToLower(roleName)
-
-		name := ""
-		if y.RoleRef.Name == "edit" {
-			name = editRolebindingFilename
-		} else {
-			name = viewRolebindingFilename
+	flags := flag.NewFlagSet("schema", flag.ExitOnError)
+	configFile := flags.String("config", "", "json or yaml file declaring the allowed environments and their defaults")
+	lenient := flags.Bool("lenient", false, "describe requests as accepted by -lenient")
+	flags.Parse(args)
+
+	config := provisioner.DefaultConfig()
+	if *configFile != "" {
+		loaded, err := provisioner.LoadConfig(*configFile)
+		if err != nil {
+			exitLog("program exited due to error loading config: " + err.Error())
 		}
-		// add to results
-		names = append(names, name)
-		bytes = append(bytes, y)
+		config = loaded
 	}
-	// now do 3
-	roleName := "admin-relman"
-	roleBindingName := strings.ToLower(data.ProjectName + "-" + roleName + "-" + "binding")
-	// create our object
-	y := roleBinding{
-		Kind:       "RoleBinding",
-		APIVersion: "rbac.authorization.k8s.io/v1",
-	}
-	y.Metadata.Name = roleBindingName
-	y.Metadata.NameSpace = data.ProjectName
-	y.Subjects = subjects{
-		subject{
-			Kind:      "ServiceAccount",
-			Name:      "relman",
-			Namespace: "relman",
-		},
+	config.Lenient = *lenient
+	p, err := provisioner.New(config)
+	if err != nil {
+		exitLog("program exited due to error loading config: " + err.Error())
 	}
-	y.RoleRef.APIGroup = "rbac.authorization.k8s.io"
-	y.RoleRef.Kind = "ClusterRole"
-	y.RoleRef.Name = "admin"
+	fmt.Println(string(p.Schema()))
+}
 
-	name := jenkinsRolebindinngFilename
+func main() {
 
-	// add to results
-	names = append(names, name)
-	bytes = append(bytes, y)
+	if len(os.Args) > 1 && os.Args[1] == "schema" {
+		runSchema(os.Args[2:])
+		return
+	}
 
-	return names, bytes
-}
+	var incomingJSON *string
+	incomingJSON = flag.String("data", "", "the json payload used to generate the OpenShift json")
+	inputFile := flag.String("file", "", "read the json or yaml payload from a file, or from STDIN when set to \"-\" (a lone \"-\" after all flags does the same)")
+	outDir := flag.String("out", "", "write each generated file to <out>/<environment>/<projectname>/ instead of STDOUT")
+	force := flag.Bool("force", false, "replace an existing project directory when used with -out")
+	configFile := flag.String("config", "", "json or yaml file declaring the allowed environments and their defaults")
+	format := flag.String("format", provisioner.FormatJSON, "output format: json, yaml, stream (multi-document yaml) or list (json List)")
+	lenient := flag.Bool("lenient", false, "accept unknown fields, duplicate optionals and missing counts, as older versions did")
+	errorFormat := flag.String("errors", "text", "how problems with the request are reported: text, or json (a document on STDERR listing every problem)")
+	flag.Parse()
 
-func createLimitsObject(data *expectedInput) (string, quota) {
-	if data.Optionals == nil {
-		// should never happen, but if so, handle it
-		return "", quota{}
+	file, err := inputArgs(flag.Args(), *inputFile)
+	if err != nil {
+		exitLog(err.Error())
 	}
-	// create our object
-	y := quota{
-		Kind:       "ResourceQuota",
-		APIVersion: "v1",
+	*inputFile = file
+	if *incomingJSON == "" && *inputFile == "" {
+		exitLog("program exited due to missing input")
 	}
-	y.Metadata.Name = "default-quotas"
-	y.Metadata.NameSpace = data.ProjectName
 
-	// now get the optionals
-	if o := data.getOptional("cpu"); o != nil {
-		// CPU can be specified with, and without a suffix - handle both
-		if o.Unit.string != "" {
-			y.Spec.Hard.CPU = concat(o.Count.int, o.Unit.string)
-		} else {
-			y.Spec.Hard.CPU = o.Count.int
+	config := provisioner.DefaultConfig()
+	if *configFile != "" {
+		loaded, err := provisioner.LoadConfig(*configFile)
+		if err != nil {
+			exitLog("program exited due to error loading config: " + err.Error())
 		}
+		config = loaded
 	}
-
-	if o := data.getOptional("memory"); o != nil {
-		y.Spec.Hard.Memory = concat(o.Count.int, o.Unit.string)
+	if *lenient {
+		config.Lenient = true
 	}
-
-	if o := data.getOptional("volumes"); o != nil {
-		y.Spec.Hard.PVC = o.Count.int
+	p, err := provisioner.New(config)
+	if err != nil {
+		exitLog("program exited due to error loading config: " + err.Error())
 	}
 
-	if o := data.getOptional("storage"); o != nil {
-		y.Spec.Hard.Storage = concat(o.Count.int, o.Unit.string)
+	if *errorFormat != "text" && *errorFormat != "json" {
+		exitLog("errors must be one of: text, json")
 	}
 
-	name := quotaFilename
-
-	return name, y
-}
-
-func concat(i int, s string) string {
-	return strconv.Itoa(i) + s
-}
-
-func process(data *expectedInput) *resultsObject {
-	/*
-		Populate our resultsObject here with each file and its contents
-	*/
-
-	results := &resultsObject{}
-	results.addPayload(createProjectObject(data))
-	results.addPayload(createRoleBindingObjects(data))
-	results.addPayload(createLimitsObject(data))
-	results.addPayload(createNetworkPolicyObject(data))
-	results.addPayload(createEgressNetworkPolicyObject(data))
-
-	return results
-}
-
-/*
-	Helpers
-*/
-
-func (results *resultsObject) addBindingsType(name []string, d interface{}) bool {
-	switch data := d.(type) {
-	case []roleBinding:
-		// now work on each set of data in turn
-		for i := range name {
-			r := resultEntry{}
-			r.Name = name[i]
-			r.Content = data[i]
-			*results = append(*results, r)
-		}
-		return true
-	default:
-		return false
+	if *outDir != "" && (*format == provisioner.FormatStream || *format == provisioner.FormatList) {
+		exitLog("format " + *format + " can only be written to STDOUT")
 	}
-}
 
-func isEmptyObject(d interface{}) bool {
-	// quotas may be empty if no limits were supplied - if so, we want to avoid adding it
-	switch object := d.(type) {
-	case quota:
-		if (quota{}) == object {
-			return true
-		}
+	payload, err := readInput(*incomingJSON, *inputFile)
+	if err != nil {
+		exitLog("program exited due to error reading input: " + err.Error())
 	}
-	return false
-}
 
-func (results *resultsObject) addPayload(f interface{}, d interface{}) {
-	/*
-		f can be a string, or []string - d can be an object, or a slice of them
-	*/
-	if isEmptyObject(d) {
-		return
+	requests, isBatch, err := provisioner.SplitRequests(payload)
+	if err != nil {
+		exitLog("program exited due to error in parsing input: " + err.Error())
 	}
-
-	switch name := f.(type) {
-	case string:
-		r := resultEntry{}
-		r.Name = name
-		r.Content = d
-		*results = append(*results, r)
-
-	case []string:
-		// assert that data is of the expected type, and add correct type
-		ok := results.addBindingsType(name, d)
-		if !ok {
-			exitLog("invalid datatype passed, expected []roleBinding")
-		}
-
-	default:
-		exitLog("invalid datatype passed")
+	if isBatch {
+		runBatch(p, requests, *outDir, *format, *force, *errorFormat)
+		return
 	}
-}
-
-func generateADGroupNames(data *expectedInput) map[string]string {
-	/*
-		AD groups names will be gererated as:
-
-		"RES" + "-" + environment + "-" + "OPSH" + "-" + role + "-" + project_name
-
-		returns a map of "OPENSHIFT ROLE" : "AD GROUP NAME"
-	*/
-	s := make(map[string]string)
-	s["EDIT"] = strings.ToUpper("RES" + "-" + data.Environment + "-" + "OPSH" + "-" + "DEVELOPER" + "-" + strings.ReplaceAll(data.ProjectName, "-", "_"))
-	s["VIEW"] = strings.ToUpper("RES" + "-" + data.Environment + "-" + "OPSH" + "-" + "VIEWER" + "-" + strings.ReplaceAll(data.ProjectName, "-", "_"))
-	return s
-}
-
-func logFunction(format string) {
-	fmt.Println(format)
-	os.Exit(1)
-}
 
-var exitLog = logFunction
-
-func main() {
-
-	var incomingJSON *string
-	incomingJSON = flag.String("data", "", "the json payload used to generate the OpenShift json")
-	flag.Parse()
-
-	if *incomingJSON == "" {
-		exitLog("program exited due to missing input")
+	// decoding verifies the input, and reports every problem with it at once
+	inputData, err := p.Decode(requests[0])
+	if err != nil {
+		requestError("program exited due to error in parsing input: ", err, *errorFormat)
 	}
 
-	var inputData expectedInput
-	// unmarshal will call our custom decoders which do input verification
-	err := json.Unmarshal([]byte(*incomingJSON), &inputData)
+	// lets go
+	rawResults, err := p.Generate(context.Background(), inputData)
 	if err != nil {
-		exitLog("program exited due to error in parsing input: " + err.Error())
+		requestError("program exited due to error generating results: ", err, *errorFormat)
+	}
+	if fromSize := inputData.FromSize(); len(fromSize) > 0 {
+		fmt.Fprintln(os.Stderr, "size "+inputData.Size+" supplied: "+strings.Join(fromSize, ", "))
+	}
+	if violations := inputData.Violations(); len(violations) > 0 {
+		fmt.Fprintln(os.Stderr, "ceilings exceeded: "+policySummary(violations))
 	}
 
-	// lets go
-	rawResults := process(&inputData)
+	if *outDir != "" {
+		err = writeResults(rawResults, *outDir, inputData.Environment, inputData.ProjectName, *format, *force)
+		if err != nil {
+			exitLog("failed to write results: " + err.Error())
+		}
+		return
+	}
 
-	// serialize data to JSON
-	data, err := json.MarshalIndent(rawResults, "", "  ")
+	// serialize data to the requested format
+	data, err := provisioner.Marshal(rawResults, *format)
 	if err != nil {
 		exitLog("serialization error: " + err.Error())
 	}
 
 	// dump result to STDOUT
-	fmt.Println(string(data))
+	fmt.Print(string(data))
 
 }
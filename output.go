@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/nicgrobler/parser/provisioner"
+)
+
+/*
+	Writing results to disk. Rather than dumping a single json blob to STDOUT, each Entry is written to
+	its own file under a per-project directory, within a directory per environment, so that the same project
+	can be written for several environments at once:
+
+		<dir>/<environment>/<projectname>/1-project.yaml
+		<dir>/<environment>/<projectname>/10-quotas.yaml
+		...
+
+	Each file is serialized in the requested format (json, or yaml).
+
+	All files are first written to a hidden staging directory next to the final one, which is then renamed into
+	place. This way a failed run never leaves a partially written project behind.
+*/
+
+func writeResults(results provisioner.Results, dir string, environment string, project string, format string, force bool) error {
+	dir = filepath.Join(dir, environment)
+	target := filepath.Join(dir, project)
+
+	// refuse to clobber an existing project unless told to
+	if !force {
+		existing, err := ioutil.ReadDir(target)
+		if err == nil && len(existing) > 0 {
+			return errors.New("refusing to overwrite existing project directory: " + target)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	staging, err := ioutil.TempDir(dir, "."+project+"-")
+	if err != nil {
+		return err
+	}
+	// once committed the staging directory no longer exists, so this only cleans up after failures
+	defer os.RemoveAll(staging)
+
+	for _, entry := range results {
+		if err := writeEntry(staging, entry, format); err != nil {
+			return err
+		}
+	}
+	// TempDir creates the directory as 0700, which is not what we want for the final project directory
+	if err := os.Chmod(staging, 0755); err != nil {
+		return err
+	}
+	return commitDirectory(staging, target)
+}
+
+func writeEntry(dir string, entry provisioner.Entry, format string) error {
+	// entries are only ever plain filenames - never allow them to escape the project directory
+	if entry.Name == "" || filepath.Base(entry.Name) != entry.Name {
+		return errors.New("invalid filename in results: " + entry.Name)
+	}
+	data, err := provisioner.MarshalObject(entry.Content, format)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, entry.Name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func commitDirectory(staging string, target string) error {
+	/*
+		swap the fully written staging directory into place. If an old project directory exists (only possible
+		when forced), it is moved aside first and restored should the final rename fail.
+	*/
+	backup := ""
+	if _, err := os.Stat(target); err == nil {
+		backup = staging + ".old"
+		if err := os.Rename(target, backup); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(staging, target); err != nil {
+		if backup != "" {
+			os.Rename(backup, target)
+		}
+		return err
+	}
+	if backup != "" {
+		return os.RemoveAll(backup)
+	}
+	return nil
+}
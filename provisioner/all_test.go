@@ -0,0 +1,2483 @@
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func findObjectIndex(name string, files []string) (int, bool) {
+	// returns the index of name
+	for i, f := range files {
+		if f == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func TestGenerateADGroupName(t *testing.T) {
+	i := Request{Environment: "boogie", ProjectName: "extra-good"}
+	got := generateADGroupNames(&i, DefaultConfig())
+	want := "RES-BOOGIE-OPSH-DEVELOPER-EXTRA_GOOD"
+	if want != got["EDIT"] {
+		t.Errorf("wanted %s, but got %s: \n", want, got)
+	}
+	want = "RES-BOOGIE-OPSH-VIEWER-EXTRA_GOOD"
+	if want != got["VIEW"] {
+		t.Errorf("wanted %s, but got %s: \n", want, got)
+	}
+}
+
+func TestCheckInputValid(t *testing.T) {
+	data := []byte(`{
+		"projectname": "nic-test-backbase-reference",
+		"role": "developer",
+		"environment": "dev",
+		"optionals":[
+					{
+						"name":"cpu",
+						"count": 1
+					},
+					{
+						"name":"memory",
+						"count":1,
+						"unit":"Gi"
+					},
+					{
+						"name":"volumes",
+						"count":2
+					}
+		]
+	}`)
+	d := Request{}
+	// role is not part of the API, so only lenient decoding accepts it
+	err := json.Unmarshal(data, &d)
+	if err == nil || err.Error() != "unknown field role, expected one of: apiVersion, kind, projectname, environment, optionals, deployers, allowIngress, egress, size, override" {
+		t.Errorf("wanted %s, but got %v: \n", "unknown field role", err)
+	}
+	lenient := DefaultConfig()
+	lenient.Lenient = true
+	p, _ := New(lenient)
+	d, err = p.Decode(data)
+	if err != nil {
+		t.Errorf("wanted %s, but got %s: \n", "nil", err.Error())
+	}
+
+	if d.Environment != "dev" {
+		t.Errorf("wanted %s, but got %s: \n", "dev", d.Environment)
+	}
+
+	if d.Optionals == nil {
+		t.Errorf("wanted %s, but got %s: \n", "optionals", "nil")
+	}
+
+	// complain about spaces
+	badData := []byte(`{
+		"projectname": "nic-test backbase-reference",
+		"environment": "dev",
+		"optionals":[
+					{
+						"name":"cpu",
+						"count": 1
+					},
+					{
+						"name":"memory",
+						"count":1,
+						"unit":"Gi"
+					},
+					{
+						"name":"volumes",
+						"count":2
+					}
+		]
+	}`)
+	d = Request{}
+	err = json.Unmarshal(badData, &d)
+	if err == nil {
+		t.Errorf("wanted %s, but got %s: \n", "an error", "nil")
+	}
+	want := "data contains illegal spaces"
+	got := err.Error()
+	if got != want {
+		t.Errorf("wanted %v, but got %v: \n", want, got)
+	}
+
+	// complain about underscores
+	badData = []byte(`{
+		"projectname": "nic_test-backbase-reference",
+		"environment": "dev",
+		"optionals":[
+					{
+						"name":"cpu",
+						"count": 1
+					},
+					{
+						"name":"memory",
+						"count":1,
+						"unit":"Gi"
+					},
+					{
+						"name":"volumes",
+						"count":2
+					}
+		]
+	}`)
+	d = Request{}
+	err = json.Unmarshal(badData, &d)
+	if err == nil {
+		t.Errorf("wanted %s, but got %s: \n", "an error", "nil")
+	}
+	want = "data contains illegal underscores"
+	got = err.Error()
+	if got != want {
+		t.Errorf("wanted %v, but got %v: \n", want, got)
+	}
+
+	// should autoformat the data
+	badData = []byte(`{
+		"projectname": "NIC-test-backbase-reference",
+		"environment": "DEV",
+		"optionals":[
+					{
+						"name":"cpu",
+						"count": 1
+					},
+					{
+						"name":"memory",
+						"count":1,
+						"unit":"Gi"
+					},
+					{
+						"name":"volumes",
+						"count":2
+					}
+		]
+	}`)
+	d = Request{}
+	err = json.Unmarshal(badData, &d)
+	if err != nil {
+		t.Errorf("wanted %s, but got %s: \n", "nil", err.Error())
+	}
+
+	if d.ProjectName != "nic-test-backbase-reference" {
+		t.Errorf("wanted %v, but got %v: \n", "nic-test-backbase-reference", d.ProjectName)
+	}
+
+	if d.Environment != "dev" {
+		t.Errorf("wanted %v, but got %v: \n", "dev", d.Environment)
+	}
+
+	// should complain about invalid name in optionals
+	badData = []byte(`{
+		"projectname": "NIC-test-backbase-reference",
+		"environment": "DEV",
+		"optionals":[
+					{
+						"name":"cpu",
+						"count": 1
+					},
+					{
+						"name":"memooory",
+						"count":1,
+						"unit":"Gi"
+					},
+					{
+						"name":"volumes",
+						"count":2
+					}
+		]
+	}`)
+	d = Request{}
+	err = json.Unmarshal(badData, &d)
+	if err == nil {
+		t.Errorf("wanted %s, but got %s: \n", "an error", "nil")
+	}
+	if err.Error() != "optional name entry is invalid: memooory" {
+		t.Errorf("wanted %s, but got %s: \n", "an error", err.Error())
+	}
+
+	// should complain about invalid unit in optionals
+	badData = []byte(`{
+		"projectname": "NIC-test-backbase-reference",
+		"environment": "DEV",
+		"optionals":[
+					{
+						"name":"cpu",
+						"count": 1
+					},
+					{
+						"name":"memory",
+						"count":1,
+						"unit":"Giz"
+					},
+					{
+						"name":"volumes",
+						"count":2
+					}
+		]
+	}`)
+	d = Request{}
+	err = json.Unmarshal(badData, &d)
+	if err == nil {
+		t.Errorf("wanted %s, but got %s: \n", "an error", "nil")
+	}
+	if err.Error() != "optional unit entry is invalid: Giz" {
+		t.Errorf("wanted %s, but got %s: \n", "optional unit entry is invalid: Giz", err.Error())
+	}
+
+	// should complain about missing unit in optionals
+	badData = []byte(`{
+		"projectname": "NIC-test-backbase-reference",
+		"environment": "DEV",
+		"optionals":[
+					{
+						"name":"cpu",
+						"count": 1
+					},
+					{
+						"name":"storage",
+						"count":1
+					},
+					{
+						"name":"volumes",
+						"count":2
+					}
+		]
+	}`)
+	d = Request{}
+	err = json.Unmarshal(badData, &d)
+	if err == nil {
+		t.Errorf("wanted %s, but got %s: \n", "an error", "nil")
+	}
+	if err.Error() != "invalid or missing unit for: storage" {
+		t.Errorf("wanted %s, but got %s: \n", "invalid or missing unit for: storage", err.Error())
+	}
+
+	// should complain about invalid count in optionals with type error
+	badData = []byte(`{
+		"projectname": "NIC-test-backbase-reference",
+		"environment": "DEV",
+		"optionals":[
+					{
+						"name":"cpu",
+						"count": 1
+					},
+					{
+						"name":"memory",
+						"count":1.1,
+						"unit":"Gi"
+					},
+					{
+						"name":"volumes",
+						"count":2
+					}
+		]
+	}`)
+	d = Request{}
+	err = json.Unmarshal(badData, &d)
+	if err == nil {
+		t.Errorf("wanted %s, but got %s: \n", "an error", "nil")
+	}
+	if err.Error() != "optionals[1].count must be an integer, found: 1.1" {
+		t.Errorf("wanted %s, but got %s: \n", "optionals[1].count must be an integer, found: 1.1", err.Error())
+	}
+
+	// should complain about invalid count in optionals with type error
+	badData = []byte(`{
+		"projectname": "NIC-test-backbase-reference",
+		"environment": "DEV",
+		"optionals":[
+					{
+						"name":"cpu",
+						"count": 1
+					},
+					{
+						"name":"memory",
+						"count":"1",
+						"unit":"Gi"
+					},
+					{
+						"name":"volumes",
+						"count":2
+					}
+		]
+	}`)
+	d = Request{}
+	err = json.Unmarshal(badData, &d)
+	if err == nil {
+		t.Errorf("wanted %s, but got %s: \n", "an error", "nil")
+	}
+	if err.Error() != "optionals[1].count must be an integer, found: \"1\"" {
+		t.Errorf("wanted %s, but got %s: \n", "optionals[1].count must be an integer, found: \"1\"", err.Error())
+	}
+
+	// should complain about invalid count in optionals with type error
+	data = []byte(`{
+		"projectname": "NIC-test-backbase-reference",
+		"environment": "DEV",
+		"optionals":[
+					{
+						"name":"cpu",
+						"count": 1000,
+						"unit": "m"
+					},
+					{
+						"name":"memory",
+						"count":1,
+						"unit":"Gi"
+					},
+					{
+						"name":"volumes",
+						"count":2
+					}
+		]
+	}`)
+	d = Request{}
+	err = json.Unmarshal(data, &d)
+	if err != nil {
+		t.Errorf("wanted %s, but got %s: \n", "nil", err.Error())
+	}
+	if d.Optionals[0].Count.int != 1000 || d.Optionals[0].Unit.string != "m" {
+		t.Errorf("wanted %s, but got %s: \n", "should be equal", "are not equal")
+	}
+
+}
+
+func TestValidUnit(t *testing.T) {
+
+	want := false
+	got := validUnit("gb")
+	if got != want {
+		t.Errorf("wanted %v, but got %v: \n", want, got)
+	}
+
+	want = true
+	got = validUnit("Mi")
+	if got != want {
+		t.Errorf("wanted %v, but got %v: \n", want, got)
+	}
+}
+
+func TestValidName(t *testing.T) {
+	want := false
+	got := validName("cpus")
+	if got != want {
+		t.Errorf("wanted %v, but got %v: \n", want, got)
+	}
+
+	want = true
+	got = validName("cpu")
+	if got != want {
+		t.Errorf("wanted %v, but got %v: \n", want, got)
+	}
+
+	want = false
+	got = validName("Memory")
+	if got != want {
+		t.Errorf("wanted %v, but got %v: \n", want, got)
+	}
+
+	want = true
+	got = validName("volumes")
+	if got != want {
+		t.Errorf("wanted %v, but got %v: \n", want, got)
+	}
+
+	want = true
+	got = validName("storage")
+	if got != want {
+		t.Errorf("wanted %v, but got %v: \n", want, got)
+	}
+
+	want = false
+	got = validName("disk")
+	if got != want {
+		t.Errorf("wanted %v, but got %v: \n", want, got)
+	}
+}
+
+func TestCreateNewProjectObject(t *testing.T) {
+
+	expectedBytes := []byte(`{"kind":"Project","apiVersion":"project.openshift.io/v1","metadata":{"name":"boogie-test"}}`)
+
+	i := Request{ProjectName: "boogie-test"}
+
+	fileName, baseObject := createProjectObject(&i)
+	gotBytes, err := json.Marshal(baseObject)
+	if err != nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	if string(expectedBytes) != string(gotBytes) {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes, gotBytes)
+	}
+	expectedObjectName := projectFilename
+	if expectedObjectName != fileName {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedObjectName, fileName)
+	}
+
+}
+
+func TestCreateNewNetworkPolicyObject(t *testing.T) {
+
+	expectedBytes := []byte(`{"kind":"NetworkPolicy","apiVersion":"networking.k8s.io/v1","metadata":{"name":"deny-by-default","namespace":"boogie-test"},"spec":{"podSelector":{},"policyTypes":["Ingress","Egress"]}}`)
+
+	i := Request{ProjectName: "boogie-test"}
+	fileName, baseObject := createNetworkPolicyObject(&i)
+	gotBytes, err := json.Marshal(baseObject)
+	if err != nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	if string(expectedBytes) != string(gotBytes) {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes, gotBytes)
+	}
+	expectedObjectName := networkPolicyFilename
+	if expectedObjectName != fileName {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedObjectName, fileName)
+	}
+
+}
+
+func TestCreateNewEgressNetworkPolicyObject(t *testing.T) {
+
+	expectedBytes := []byte(`{"kind":"EgressNetworkPolicy","apiVersion":"network.openshift.io/v1","metadata":{"name":"default-egress","namespace":"boogie-test"},"spec":{"egress":[{"type":"Deny","to":{"cidrSelector":"0.0.0.0/0"}}]}}`)
+
+	i := Request{ProjectName: "boogie-test"}
+
+	fileName, baseObject := createEgressNetworkPolicyObject(&i)
+	gotBytes, err := json.Marshal(baseObject)
+	if err != nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	if string(expectedBytes) != string(gotBytes) {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes, gotBytes)
+	}
+	expectedObjectName := egressNetworkPolicyFilename
+	if expectedObjectName != fileName {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedObjectName, fileName)
+	}
+
+}
+
+func TestCreateNewRoleBindingObject(t *testing.T) {
+
+	expectedBytes := make(map[string][]byte)
+
+	expectedBytes[editRolebindingFilename] = []byte(`{"kind":"RoleBinding","apiVersion":"rbac.authorization.k8s.io/v1","metadata":{"name":"boogie-test-edit-binding","namespace":"boogie-test"},"subjects":[{"kind":"Group","apiGroup":"rbac.authorization.k8s.io","name":"RES-DEV-OPSH-DEVELOPER-BOOGIE_TEST"}],"roleRef":{"kind":"ClusterRole","apiGroup":"rbac.authorization.k8s.io","name":"edit"}}`)
+	expectedBytes[viewRolebindingFilename] = []byte(`{"kind":"RoleBinding","apiVersion":"rbac.authorization.k8s.io/v1","metadata":{"name":"boogie-test-view-binding","namespace":"boogie-test"},"subjects":[{"kind":"Group","apiGroup":"rbac.authorization.k8s.io","name":"RES-DEV-OPSH-VIEWER-BOOGIE_TEST"}],"roleRef":{"kind":"ClusterRole","apiGroup":"rbac.authorization.k8s.io","name":"view"}}`)
+	expectedBytes[jenkinsRolebindinngFilename] = []byte(`{"kind":"RoleBinding","apiVersion":"rbac.authorization.k8s.io/v1","metadata":{"name":"boogie-test-admin-relman-binding","namespace":"boogie-test"},"subjects":[{"kind":"ServiceAccount","name":"relman","namespace":"relman"}],"roleRef":{"kind":"ClusterRole","apiGroup":"rbac.authorization.k8s.io","name":"admin"}}`)
+
+	i := Request{ProjectName: "boogie-test", Environment: "dev"}
+
+	fileNames, baseObject := createRoleBindingObjects(&i, DefaultConfig())
+	expectedObjectName := editRolebindingFilename
+
+	index, found := findObjectIndex(expectedObjectName, fileNames)
+	// verify file is in list
+	if !found {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "true", "false")
+	}
+	// verify contents are correct
+	gotBytes, err := json.Marshal(baseObject[index])
+	if err != nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	if string(expectedBytes[expectedObjectName]) != string(gotBytes) {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes[expectedObjectName], gotBytes)
+	}
+
+	expectedObjectName = viewRolebindingFilename
+	index, found = findObjectIndex(expectedObjectName, fileNames)
+	// verify file is in list
+	if !found {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "true", "false")
+	}
+
+	gotBytes, err = json.Marshal(baseObject[index])
+	if err != nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	if string(expectedBytes[expectedObjectName]) != string(gotBytes) {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes[expectedObjectName], gotBytes)
+	}
+
+	expectedObjectName = jenkinsRolebindinngFilename
+	index, found = findObjectIndex(expectedObjectName, fileNames)
+	// verify file is in list
+	if !found {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "true", "false")
+	}
+	gotBytes, err = json.Marshal(baseObject[index])
+	if err != nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	if string(expectedBytes[expectedObjectName]) != string(gotBytes) {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes[expectedObjectName], gotBytes)
+	}
+
+}
+
+func TestCreateNewLimitsObject(t *testing.T) {
+	expectedBytes := []byte(`{"kind":"ResourceQuota","apiVersion":"v1","metadata":{"name":"default-quotas","namespace":"boogie-test"},"spec":{"hard":{"limits.cpu":2,"limits.memory":"1Gi","persistentvolumeclaims":3,"requests.storage":"100Gi"}}}`)
+
+	o := []Optional{
+		Optional{
+			Name:  oName{"cpu"},
+			Count: oCount{2},
+		},
+		Optional{
+			Name:  oName{"memory"},
+			Count: oCount{1},
+			Unit:  oUnit{"Gi"},
+		},
+		Optional{
+			Name:  oName{"volumes"},
+			Count: oCount{3},
+		},
+		Optional{
+			Name:  oName{"storage"},
+			Count: oCount{100},
+			Unit:  oUnit{"Gi"},
+		},
+	}
+
+	i := Request{ProjectName: "boogie-test", Environment: "dev", Optionals: o}
+
+	fileName, baseObject := createLimitsObject(&i)
+	// verify contents are correct
+	gotBytes, err := json.Marshal(baseObject)
+	if err != nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	if string(expectedBytes) != string(gotBytes) {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes, gotBytes)
+	}
+	expectedObjectName := quotaFilename
+	if expectedObjectName != fileName {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedObjectName, fileName)
+	}
+
+	expectedBytes = []byte(`{"kind":"ResourceQuota","apiVersion":"v1","metadata":{"name":"default-quotas","namespace":"boogie-test"},"spec":{"hard":{"limits.cpu":1,"limits.memory":"5Gi","requests.storage":"5Gi"}}}`)
+
+	o = []Optional{
+		Optional{
+			Name:  oName{"cpu"},
+			Count: oCount{1},
+		},
+		Optional{
+			Name:  oName{"memory"},
+			Count: oCount{5},
+			Unit:  oUnit{"Gi"},
+		},
+		Optional{
+			Name:  oName{"storage"},
+			Count: oCount{5},
+			Unit:  oUnit{"Gi"},
+		}}
+
+	i = Request{ProjectName: "boogie-test", Environment: "dev", Optionals: o}
+
+	fileName, baseObject = createLimitsObject(&i)
+	// verify contents are correct
+	gotBytes, err = json.Marshal(baseObject)
+	if err != nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	if string(expectedBytes) != string(gotBytes) {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes, gotBytes)
+	}
+
+	expectedObjectName = quotaFilename
+	if expectedObjectName != fileName {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedObjectName, fileName)
+	}
+
+	expectedBytes = []byte(`{"kind":"","apiVersion":"","metadata":{"name":""},"spec":{"hard":{}}}`)
+
+	i = Request{ProjectName: "boogie-test", Environment: "dev"}
+
+	fileName, baseObject = createLimitsObject(&i)
+	// verify contents are correct
+	gotBytes, err = json.Marshal(baseObject)
+	if err != nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	if string(expectedBytes) != string(gotBytes) {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes, gotBytes)
+	}
+	expectedObjectName = ""
+	if expectedObjectName != fileName {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedObjectName, fileName)
+	}
+}
+
+func TestIsEmptyObject(t *testing.T) {
+	q := quota{}
+	isEmpty := isEmptyObject(q)
+	if !isEmpty {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "empty", "not empty")
+	}
+
+	q = quota{}
+	q.Kind = "whatever"
+
+	isEmpty = isEmptyObject(q)
+	if isEmpty {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "not empty", "empty")
+	}
+}
+
+func TestCreateNewLimitsObjectCPU(t *testing.T) {
+	expectedBytes := []byte(`{"kind":"ResourceQuota","apiVersion":"v1","metadata":{"name":"default-quotas","namespace":"boogie-test"},"spec":{"hard":{"limits.cpu":"200m","limits.memory":"1Gi","persistentvolumeclaims":3}}}`)
+
+	o := []Optional{
+		Optional{
+			Name:  oName{"cpu"},
+			Count: oCount{200},
+			Unit:  oUnit{"m"},
+		},
+		Optional{
+			Name:  oName{"memory"},
+			Count: oCount{1},
+			Unit:  oUnit{"Gi"},
+		},
+		Optional{
+			Name:  oName{"volumes"},
+			Count: oCount{3},
+		}}
+
+	i := Request{ProjectName: "boogie-test", Environment: "dev", Optionals: o}
+
+	fileName, baseObject := createLimitsObject(&i)
+	// verify contents are correct
+	gotBytes, err := json.Marshal(baseObject)
+	if err != nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	if string(expectedBytes) != string(gotBytes) {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes, gotBytes)
+	}
+	expectedObjectName := quotaFilename
+	if expectedObjectName != fileName {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedObjectName, fileName)
+	}
+
+}
+
+func TestMarshalYAML(t *testing.T) {
+	expectedBytes := []byte(`kind: ResourceQuota
+apiVersion: v1
+metadata:
+  name: default-quotas
+  namespace: boogie-test
+spec:
+  hard:
+    limits.cpu: 200m
+    limits.memory: 1Gi
+    persistentvolumeclaims: 3
+`)
+	o := []Optional{
+		Optional{
+			Name:  oName{"cpu"},
+			Count: oCount{200},
+			Unit:  oUnit{"m"},
+		},
+		Optional{
+			Name:  oName{"memory"},
+			Count: oCount{1},
+			Unit:  oUnit{"Gi"},
+		},
+		Optional{
+			Name:  oName{"volumes"},
+			Count: oCount{3},
+		}}
+
+	i := Request{ProjectName: "boogie-test", Environment: "dev", Optionals: o}
+
+	_, baseObject := createLimitsObject(&i)
+	gotBytes, err := marshalYAML(baseObject)
+	if err != nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	if string(expectedBytes) != string(gotBytes) {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes, gotBytes)
+	}
+
+	// sequences of mappings, empty collections and values that need quoting
+	expectedBytes = []byte(`kind: NetworkPolicy
+apiVersion: networking.k8s.io/v1
+metadata:
+  name: deny-by-default
+  namespace: boogie-test
+spec:
+  podSelector: {}
+  policyTypes:
+  - Ingress
+  - Egress
+`)
+	_, networkObject := createNetworkPolicyObject(&i)
+	gotBytes, err = marshalYAML(networkObject)
+	if err != nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	if string(expectedBytes) != string(gotBytes) {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes, gotBytes)
+	}
+
+	// maps are written in sorted key order
+	expectedBytes = []byte(`- content:
+    spec:
+      egress:
+      - to:
+          cidrSelector: 0.0.0.0/0
+        type: Deny
+  filename: 10-egress-networkpolicy.yaml
+- content:
+  - "true"
+  - "10"
+  - ""
+  - "- dash"
+  - "a: b"
+  - null
+  filename: quoted
+`)
+	v := []interface{}{
+		map[string]interface{}{
+			"filename": "10-egress-networkpolicy.yaml",
+			"content": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"egress": []interface{}{
+						map[string]interface{}{"type": "Deny", "to": map[string]string{"cidrSelector": "0.0.0.0/0"}},
+					},
+				},
+			},
+		},
+		map[string]interface{}{
+			"filename": "quoted",
+			"content":  []interface{}{"true", "10", "", "- dash", "a: b", nil},
+		},
+	}
+	gotBytes, err = marshalYAML(v)
+	if err != nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	if string(expectedBytes) != string(gotBytes) {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes, gotBytes)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	// project deliberately placed last, it must still come out first
+	results := Results{
+		Entry{Name: quotaFilename, Content: map[string]string{"kind": "ResourceQuota"}},
+		Entry{Name: "unprefixed.yaml", Content: map[string]string{"kind": "ConfigMap"}},
+		Entry{Name: networkPolicyFilename, Content: map[string]string{"kind": "NetworkPolicy"}},
+		Entry{Name: projectFilename, Content: map[string]string{"kind": "Project"}},
+	}
+
+	expectedBytes := []byte(`---
+kind: Project
+---
+kind: ResourceQuota
+---
+kind: NetworkPolicy
+---
+kind: ConfigMap
+`)
+	gotBytes, err := Marshal(results, FormatStream)
+	if err != nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	if string(expectedBytes) != string(gotBytes) {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes, gotBytes)
+	}
+
+	expectedBytes = []byte(`{"kind":"List","apiVersion":"v1","items":[{"kind":"Project"},{"kind":"ResourceQuota"},{"kind":"NetworkPolicy"},{"kind":"ConfigMap"}]}`)
+	gotBytes, err = Marshal(results, FormatList)
+	if err != nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	var compacted bytes.Buffer
+	json.Compact(&compacted, gotBytes)
+	if string(expectedBytes) != compacted.String() {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes, compacted.String())
+	}
+
+	// the original results are left untouched
+	if results[0].Name != quotaFilename {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", quotaFilename, results[0].Name)
+	}
+
+	_, err = Marshal(results, "xml")
+	if err == nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "an error", "nil")
+	}
+}
+
+func TestYAMLToJSON(t *testing.T) {
+	data := []byte(`---
+# comments are ignored
+projectname: boogie-test   # so are trailing ones
+environment: "dev"
+quoted: 'it''s # not a comment'
+flow: [1, two, {three: 3}]
+empty: {}
+nothing:
+optionals:
+- name: cpu
+  count: 1000
+  unit: m
+-
+  name: memory
+  nested:
+    - true
+    - ~
+`)
+	expectedBytes := []byte(`{"empty":{},"environment":"dev","flow":[1,"two",{"three":3}],"nothing":null,"optionals":[{"count":1000,"name":"cpu","unit":"m"},{"name":"memory","nested":[true,null]}],"projectname":"boogie-test","quoted":"it's # not a comment"}`)
+	gotBytes, err := yamlToJSON(data)
+	if err != nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	if string(expectedBytes) != string(gotBytes) {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes, gotBytes)
+	}
+
+	badData := map[string]string{
+		"duplicate":     "a: 1\na: 2\n",
+		"indentation":   "a: 1\n  b: 2\n",
+		"multiple docs": "a: 1\n---\na: 2\n",
+		"block scalar":  "a: |\n  text\n",
+		"flow":          "a: [1, 2\n",
+	}
+	for name, bad := range badData {
+		_, err = yamlToJSON([]byte(bad))
+		if err == nil {
+			t.Errorf("%s: wanted \n%s, \nbut got \n%s \n", name, "an error", "nil")
+		}
+	}
+}
+
+func TestDecodeRequest(t *testing.T) {
+	// yaml and json requests decode to the same thing
+	jsonData := []byte(`{"projectname": "NIC-test", "environment": "dev", "optionals": [{"name": "memory", "count": 1, "unit": "Gi"}]}`)
+	yamlData := []byte(`
+projectname: NIC-test
+environment: dev
+optionals:
+  - name: memory
+    count: 1
+    unit: Gi
+`)
+	fromJSON, err := DecodeRequest(jsonData)
+	if err != nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	fromYAML, err := DecodeRequest(yamlData)
+	if err != nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	if fromYAML.ProjectName != "nic-test" || len(fromYAML.Optionals) != 1 || fromYAML.Optionals[0] != fromJSON.Optionals[0] {
+		t.Errorf("wanted \n%v, \nbut got \n%v \n", fromJSON, fromYAML)
+	}
+
+	// and go through the same validation
+	yamlData = []byte(`
+projectname: NIC-test
+environment: dev
+optionals:
+  - name: storage
+    count: 1
+`)
+	_, err = DecodeRequest(yamlData)
+	if err == nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "an error", "nil")
+	} else if err.Error() != "invalid or missing unit for: storage" {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "invalid or missing unit for: storage", err.Error())
+	}
+
+	_, err = DecodeRequest([]byte("  \n"))
+	if err == nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "an error", "nil")
+	}
+}
+
+func TestSplitRequests(t *testing.T) {
+	inputs := map[string]struct {
+		data    string
+		count   int
+		isBatch bool
+	}{
+		"single object": {`{"projectname": "a", "environment": "dev"}`, 1, false},
+		"single yaml":   {"projectname: a\nenvironment: dev\n", 1, false},
+		"array":         {`[{"projectname": "a", "environment": "dev"}, {"projectname": "b", "environment": "dev"}]`, 2, true},
+		"array of one":  {`[{"projectname": "a", "environment": "dev"}]`, 1, true},
+		"json lines":    {"{\"projectname\": \"a\", \"environment\": \"dev\"}\n{\"projectname\": \"b\", \"environment\": \"dev\"}\n{}\n", 3, true},
+		"yaml sequence": {"- projectname: a\n  environment: dev\n- projectname: b\n  environment: dev\n", 2, true},
+	}
+	for name, input := range inputs {
+		requests, isBatch, err := SplitRequests([]byte(input.data))
+		if err != nil {
+			t.Errorf("%s: wanted \n%s, \nbut got \n%s \n", name, "no error", err.Error())
+			continue
+		}
+		if len(requests) != input.count || isBatch != input.isBatch {
+			t.Errorf("%s: wanted \n%d %v, \nbut got \n%d %v \n", name, input.count, input.isBatch, len(requests), isBatch)
+		}
+	}
+
+	for _, bad := range []string{"", "[]", "{\"projectname\": \"a\"}\n{broken"} {
+		_, _, err := SplitRequests([]byte(bad))
+		if err == nil {
+			t.Errorf("wanted \n%s, \nbut got \n%s \n", "an error", "nil")
+		}
+	}
+}
+
+func TestGenerateBatch(t *testing.T) {
+	requests, _, _ := SplitRequests([]byte(`[
+		{"projectname": "team-a", "environment": "dev"},
+		{"projectname": "team b", "environment": "dev"},
+		{"projectname": "team-a", "environment": "test"},
+		{"projectname": "team-c", "environment": "dev", "optionals": [{"name": "cpu", "count": 2}]},
+		{"projectname": "Team-A", "environment": "DEV"}
+	]`))
+	batch := GenerateBatch(context.Background(), requests)
+	if len(batch) != 5 {
+		t.Fatalf("wanted \n%d, \nbut got \n%d \n", 5, len(batch))
+	}
+	// a failure does not stop the requests after it, and a project may be requested once for each environment
+	if batch[0].Err != nil || batch[2].Err != nil || batch[3].Err != nil {
+		t.Errorf("wanted \n%s, \nbut got \n%v %v %v \n", "no error", batch[0].Err, batch[2].Err, batch[3].Err)
+	}
+	if batch[1].Err == nil || batch[1].Err.Error() != "data contains illegal spaces" {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", "data contains illegal spaces", batch[1].Err)
+	}
+	message := "duplicate projectname for environment dev, already requested by entry 1"
+	if batch[4].Err == nil || batch[4].Err.Error() != message {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", message, batch[4].Err)
+	}
+	if _, found := findObjectIndex(quotaFilename, resultNames(batch[3].Results)); !found {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "quota for team-c", "none")
+	}
+
+	// only the successful projects are rendered
+	gotBytes, err := MarshalBatch(batch, FormatJSON)
+	if err != nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	var rendered []BatchResult
+	json.Unmarshal(gotBytes, &rendered)
+	if len(rendered) != 3 || rendered[0].ProjectName != "team-a" || rendered[1].Environment != "test" || rendered[2].ProjectName != "team-c" {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "team-a for dev and test, and team-c", gotBytes)
+	}
+
+	// and only the failed ones are described by the error document
+	var document struct {
+		Requests []struct {
+			Index  int                `json:"index"`
+			Errors []*ValidationError `json:"errors"`
+		} `json:"requests"`
+	}
+	json.Unmarshal(BatchErrorDocument(batch), &document)
+	if len(document.Requests) != 2 || document.Requests[0].Index != 2 || document.Requests[0].Errors[0].Rule != "no-spaces" || document.Requests[1].Errors[0].Rule != "unique" {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "entries 2 and 5", BatchErrorDocument(batch))
+	}
+}
+
+func resultNames(results Results) []string {
+	var names []string
+	for _, entry := range results {
+		names = append(names, entry.Name)
+	}
+	return names
+}
+
+func TestGenerate(t *testing.T) {
+	// requests built in code are validated, and normalized, just like decoded ones
+	cpu, err := NewOptional("CPU", 2, "")
+	if err != nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	i := Request{ProjectName: "Boogie-Test", Environment: "dev", Optionals: []Optional{cpu}}
+	results, err := Generate(context.Background(), i)
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	if len(results) != 8 {
+		t.Errorf("wanted \n%d, \nbut got \n%d \n", 8, len(results))
+	}
+	if _, found := findObjectIndex(quotaFilename, resultNames(results)); !found {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "a quota", "none")
+	}
+	gotBytes, _ := json.Marshal(results[0].Content)
+	expectedBytes := []byte(`{"kind":"Project","apiVersion":"project.openshift.io/v1","metadata":{"name":"boogie-test"}}`)
+	if string(expectedBytes) != string(gotBytes) {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes, gotBytes)
+	}
+
+	// invalid requests give a typed error
+	i = Request{ProjectName: "boogie test", Environment: "dev"}
+	_, err = Generate(context.Background(), i)
+	validationErr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("wanted \n%s, \nbut got \n%v \n", "a ValidationError", err)
+	}
+	if validationErr.Field != "projectname" || validationErr.Error() != "data contains illegal spaces" {
+		t.Errorf("wanted \n%s, \nbut got \n%s: %s \n", "projectname: data contains illegal spaces", validationErr.Field, validationErr.Error())
+	}
+
+	// as do those that fail decoding
+	_, err = DecodeRequest([]byte(`{"projectname": "a", "environment": "dev", "optionals": [{"name": "disk", "count": 1}]}`))
+	if validationErr, ok := err.(*ValidationError); !ok || validationErr.Value != "disk" {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", "a ValidationError for disk", err)
+	}
+
+	// and those of the optional constructors, with the same path and rule as when decoding
+	_, err = NewOptional("memory", 1, "Giz")
+	if validationErr, ok := err.(*ValidationError); !ok || validationErr.Path != "unit" || validationErr.Rule != "enum" {
+		t.Errorf("wanted \n%s, \nbut got \n%#v \n", "a ValidationError for unit, rule enum", err)
+	}
+	_, err = NewOptional("disk", 1, "")
+	if validationErr, ok := err.(*ValidationError); !ok || validationErr.Path != "name" || validationErr.Rule != "enum" {
+		t.Errorf("wanted \n%s, \nbut got \n%#v \n", "a ValidationError for name, rule enum", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Generate(ctx, Request{ProjectName: "boogie-test", Environment: "dev"})
+	if err != context.Canceled {
+		t.Errorf("wanted \n%v, \nbut got \n%v \n", context.Canceled, err)
+	}
+
+	_, err = Marshal(results, "xml")
+	if _, ok := err.(*FormatError); !ok {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", "a FormatError", err)
+	}
+}
+
+func TestAddPayload(t *testing.T) {
+	results := Results{}
+	err := results.addPayload([]string{"a", "b"}, []string{"not", "bindings"})
+	if _, ok := err.(*InternalError); !ok {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", "an InternalError", err)
+	}
+	err = results.addPayload(1, quota{Kind: "ResourceQuota"})
+	if _, ok := err.(*InternalError); !ok {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", "an InternalError", err)
+	}
+	// empty quotas are silently skipped
+	err = results.addPayload(quotaFilename, quota{})
+	if err != nil || len(results) != 0 {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", "nothing added", err)
+	}
+}
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestGolden(t *testing.T) {
+	/*
+		every testdata/<name>.request.* file is generated in each output format and compared byte for byte with
+		testdata/<name>.<format>.golden. Run "go test -update" to rewrite them after an intended change.
+	*/
+	requests, err := filepath.Glob(filepath.Join("testdata", "*.request.*"))
+	if err != nil || len(requests) == 0 {
+		t.Fatalf("wanted \n%s, \nbut got \n%v \n", "request files in testdata", err)
+	}
+	for _, requestFile := range requests {
+		name := strings.SplitN(filepath.Base(requestFile), ".", 2)[0]
+		data, err := ioutil.ReadFile(requestFile)
+		if err != nil {
+			t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+		}
+		req, err := DecodeRequest(data)
+		if err != nil {
+			t.Errorf("%s: wanted \n%s, \nbut got \n%s \n", name, "no error", err.Error())
+			continue
+		}
+
+		for _, format := range []string{FormatJSON, FormatYAML, FormatStream, FormatList} {
+			goldenFile := filepath.Join("testdata", name+"."+format+".golden")
+
+			// generate a number of times, every run has to produce exactly the same bytes
+			var gotBytes []byte
+			for run := 0; run < 20; run++ {
+				results, err := Generate(context.Background(), req)
+				if err != nil {
+					t.Fatalf("%s: wanted \n%s, \nbut got \n%s \n", name, "no error", err.Error())
+				}
+				rendered, err := Marshal(results, format)
+				if err != nil {
+					t.Fatalf("%s: wanted \n%s, \nbut got \n%s \n", name, "no error", err.Error())
+				}
+				if run > 0 && string(rendered) != string(gotBytes) {
+					t.Fatalf("%s: output differs between runs: \n%s \n%s \n", goldenFile, gotBytes, rendered)
+				}
+				gotBytes = rendered
+			}
+
+			if *update {
+				if err := ioutil.WriteFile(goldenFile, gotBytes, 0644); err != nil {
+					t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+				}
+			}
+			expectedBytes, err := ioutil.ReadFile(goldenFile)
+			if err != nil {
+				t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+				continue
+			}
+			if string(expectedBytes) != string(gotBytes) {
+				t.Errorf("%s: wanted \n%s, \nbut got \n%s \n", goldenFile, expectedBytes, gotBytes)
+			}
+		}
+	}
+}
+
+func TestValidateProjectName(t *testing.T) {
+	valid := []string{"a", "boogie-test", "1-2-3", "Upper-Case", strings.Repeat("a", 42)}
+	for _, name := range valid {
+		i := Request{ProjectName: name, Environment: "dev"}
+		if err := i.validate(DefaultConfig()); err != nil {
+			t.Errorf("%s: wanted \n%s, \nbut got \n%s \n", name, "no error", err.Error())
+		}
+	}
+
+	invalid := map[string]string{
+		"boogie.test":           "projectname must consist of lower case alphanumeric characters or '-', found: .",
+		"ÄÖÜ":                   "projectname must consist of lower case alphanumeric characters or '-', found: ä",
+		"-boogie":               "projectname must start and end with an alphanumeric character",
+		"boogie-":               "projectname must start and end with an alphanumeric character",
+		strings.Repeat("a", 80): "projectname must be no more than 63 characters",
+		// fits in a label on its own, but not once the role binding suffix is added
+		strings.Repeat("a", 43): "projectname is too long, generated name " + strings.Repeat("a", 43) + "-admin-relman-binding must be no more than 63 characters",
+	}
+	for name, want := range invalid {
+		i := Request{ProjectName: name, Environment: "dev"}
+		err := i.validate(DefaultConfig())
+		if err == nil {
+			t.Errorf("%s: wanted \n%s, \nbut got \n%s \n", name, want, "nil")
+			continue
+		}
+		if err.Error() != want {
+			t.Errorf("%s: wanted \n%s, \nbut got \n%s \n", name, want, err.Error())
+		}
+		if err.(*ValidationError).Field != "projectname" {
+			t.Errorf("%s: wanted \n%s, \nbut got \n%s \n", name, "projectname", err.(*ValidationError).Field)
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	config, err := LoadConfig(filepath.Join("testdata", "config.yaml"))
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	p, err := New(config)
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+
+	// unknown environments are rejected while decoding
+	_, err = p.Decode([]byte(`{"projectname": "boogie-test", "environment": "prd"}`))
+	want := "environment prd is not one of: dev, prod, test"
+	if err == nil || err.Error() != want {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", want, err)
+	}
+
+	// defaults fill in whatever the request did not ask for
+	d, err := p.Decode([]byte(`{"projectname": "boogie-test", "environment": "DEV", "optionals": [{"name": "memory", "count": 1, "unit": "Gi"}]}`))
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	if o := d.getOptional("memory"); o == nil || o.Count.int != 1 {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", "requested memory", o)
+	}
+	if o := d.getOptional("cpu"); o == nil || o.Count.int != 2 {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", "default cpu", o)
+	}
+
+	// environment names in the config are case insensitive
+	_, err = p.Decode([]byte(`{"projectname": "boogie-test", "environment": "prod"}`))
+	if err != nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+
+	// invalid configs are refused
+	if _, err := New(&Config{}); err == nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "an error", "nil")
+	}
+	storage := Optional{Name: oName{"storage"}, Count: oCount{1}}
+	if _, err := New(&Config{Environments: map[string]EnvironmentConfig{"dev": EnvironmentConfig{Optionals: []Optional{storage}}}}); err == nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "an error", "nil")
+	}
+
+	// names that only differ in case are the same environment, or size
+	config = DefaultConfig()
+	config.Environments["Prod"] = config.Environments["prod"]
+	if _, err := New(config); err == nil || err.Error() != "environment prod is defined more than once" {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", "environment prod is defined more than once", err)
+	}
+	config = DefaultConfig()
+	config.Sizes["Small"] = config.Sizes["small"]
+	if _, err := New(config); err == nil || err.Error() != "size small is defined more than once" {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", "size small is defined more than once", err)
+	}
+}
+
+func TestProvisioner(t *testing.T) {
+	// provisioners with different configs work side by side, without touching the default
+	site, err := LoadConfig(filepath.Join("testdata", "config.yaml"))
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	p, err := New(site)
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	data := []byte(`{"projectname": "boogie-test", "environment": "acc"}`)
+
+	errs := make(chan error, 2)
+	go func() {
+		_, err := p.Decode(data)
+		errs <- err
+	}()
+	go func() {
+		_, err := DecodeRequest(data)
+		errs <- err
+	}()
+	var failed int
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			failed++
+			if err.Error() != "environment acc is not one of: dev, prod, test" {
+				t.Errorf("wanted \n%s, \nbut got \n%s \n", "environment acc is not one of: dev, prod, test", err.Error())
+			}
+		}
+	}
+	if failed != 1 {
+		t.Errorf("wanted \n%s, \nbut got \n%d \n", "only the site config to refuse acc", failed)
+	}
+
+	// the package level functions follow SetConfig
+	if err := SetConfig(site); err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	_, err = DecodeRequest(data)
+	SetConfig(DefaultConfig())
+	if err == nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "an error", "nil")
+	}
+	if err := SetConfig(&Config{}); err == nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "an error", "nil")
+	}
+}
+
+func TestGroupConfig(t *testing.T) {
+	config := DefaultConfig()
+	config.Groups = GroupConfig{
+		Template: "GRP_{{upper .Environment}}_{{.Role}}_{{lower .Project}}",
+		Roles: []GroupRole{
+			GroupRole{ClusterRole: "admin", Name: "Owner"},
+			GroupRole{ClusterRole: "view", Name: "Reader"},
+			GroupRole{ClusterRole: "monitoring-edit", Name: "Monitor"},
+		},
+	}
+	p, err := New(config)
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+
+	i := Request{Environment: "dev", ProjectName: "Extra-Good"}
+	results, err := p.Generate(context.Background(), i)
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+
+	// bindings follow the configured order, and are named after their ClusterRole
+	expected := []string{"10-admin-group-rolebinding.yaml", "10-view-group-rolebinding.yaml", "10-monitoring-edit-group-rolebinding.yaml", jenkinsRolebindinngFilename}
+	names := resultNames(results)
+	for n, name := range expected {
+		if names[n+1] != name {
+			t.Errorf("wanted \n%s, \nbut got \n%s \n", name, names[n+1])
+		}
+	}
+	gotBytes, _ := json.Marshal(results[3].Content)
+	expectedBytes := []byte(`{"kind":"RoleBinding","apiVersion":"rbac.authorization.k8s.io/v1","metadata":{"name":"extra-good-monitoring-edit-binding","namespace":"extra-good"},"subjects":[{"kind":"Group","apiGroup":"rbac.authorization.k8s.io","name":"GRP_DEV_Monitor_extra-good"}],"roleRef":{"kind":"ClusterRole","apiGroup":"rbac.authorization.k8s.io","name":"monitoring-edit"}}`)
+	if string(expectedBytes) != string(gotBytes) {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes, gotBytes)
+	}
+
+	badGroups := []GroupConfig{
+		GroupConfig{Template: "{{upper .Environment"},
+		GroupConfig{Template: "{{.Team}}"},
+		GroupConfig{Template: "{{/* nothing */}}"},
+		GroupConfig{Roles: []GroupRole{GroupRole{ClusterRole: "Edit"}}},
+		GroupConfig{Roles: []GroupRole{GroupRole{ClusterRole: "edit"}, GroupRole{ClusterRole: "edit"}}},
+		GroupConfig{Roles: []GroupRole{GroupRole{ClusterRole: "system:image-puller"}}},
+		GroupConfig{Roles: []GroupRole{GroupRole{ClusterRole: "team/edit"}}},
+		GroupConfig{Roles: []GroupRole{GroupRole{ClusterRole: "-edit"}}},
+	}
+	for _, groups := range badGroups {
+		config := DefaultConfig()
+		config.Groups = groups
+		if _, err := New(config); err == nil {
+			t.Errorf("%v: wanted \n%s, \nbut got \n%s \n", groups, "an error", "nil")
+		}
+	}
+	config = DefaultConfig()
+	config.Groups = badGroups[5]
+	message := "group role has an invalid clusterRole, it must be a DNS-1123 label: system:image-puller"
+	if _, err := New(config); err == nil || err.Error() != message {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", message, err)
+	}
+}
+
+func TestDeployers(t *testing.T) {
+	config := DefaultConfig()
+	config.Deployers = map[string]DeployerConfig{
+		"relman": DeployerConfig{ServiceAccount: "relman", Namespace: "relman", ClusterRole: "admin", Filename: jenkinsRolebindinngFilename},
+		"tekton": DeployerConfig{ServiceAccount: "pipeline", Namespace: "tekton-ci", ClusterRole: "edit"},
+	}
+	config.DefaultDeployers = []string{"relman"}
+	p, err := New(config)
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+
+	// not mentioned: the defaults
+	d, err := p.Decode([]byte(`{"projectname": "boogie-test", "environment": "dev"}`))
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	names, _ := createRoleBindingObjects(&d, p.config)
+	if _, found := findObjectIndex(jenkinsRolebindinngFilename, names); !found || len(names) != 3 {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", "the relman binding", names)
+	}
+
+	// selected explicitly
+	d, err = p.Decode([]byte(`{"projectname": "boogie-test", "environment": "dev", "deployers": ["relman", "Tekton"]}`))
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	names, bindings := createRoleBindingObjects(&d, p.config)
+	expectedBytes := []byte(`{"kind":"RoleBinding","apiVersion":"rbac.authorization.k8s.io/v1","metadata":{"name":"boogie-test-edit-tekton-binding","namespace":"boogie-test"},"subjects":[{"kind":"ServiceAccount","name":"pipeline","namespace":"tekton-ci"}],"roleRef":{"kind":"ClusterRole","apiGroup":"rbac.authorization.k8s.io","name":"edit"}}`)
+	index, found := findObjectIndex("10-tekton-deployer-rolebinding.yaml", names)
+	if !found || len(names) != 4 {
+		t.Fatalf("wanted \n%s, \nbut got \n%v \n", "relman and tekton bindings", names)
+	}
+	gotBytes, _ := json.Marshal(bindings[index])
+	if string(expectedBytes) != string(gotBytes) {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes, gotBytes)
+	}
+
+	// not deployed by automation at all
+	d, err = p.Decode([]byte("projectname: boogie-test\nenvironment: dev\ndeployers: []\n"))
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	names, _ = createRoleBindingObjects(&d, p.config)
+	if len(names) != 2 {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", "only the group bindings", names)
+	}
+
+	invalid := map[string]string{
+		`["jenkins"]`:          "deployer jenkins is not one of: relman, tekton",
+		`["tekton", "tekton"]`: "deployer is listed more than once: tekton",
+	}
+	for deployers, want := range invalid {
+		_, err = p.Decode([]byte(`{"projectname": "boogie-test", "environment": "dev", "deployers": ` + deployers + `}`))
+		if err == nil || err.Error() != want {
+			t.Errorf("wanted \n%s, \nbut got \n%v \n", want, err)
+		}
+	}
+
+	badConfig := DefaultConfig()
+	badConfig.Deployers = map[string]DeployerConfig{"tekton": DeployerConfig{ServiceAccount: "pipeline"}}
+	if _, err := New(badConfig); err == nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "an error", "nil")
+	}
+	badConfig = DefaultConfig()
+	badConfig.DefaultDeployers = []string{"jenkins"}
+	if _, err := New(badConfig); err == nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "an error", "nil")
+	}
+
+	// filenames are checked when the config is loaded, rather than when the files are written
+	badDeployers := map[string]DeployerConfig{
+		"deployer tekton filename must be a plain file name: ../tekton.yaml":                          DeployerConfig{ServiceAccount: "pipeline", Namespace: "tekton-ci", ClusterRole: "edit", Filename: "../tekton.yaml"},
+		"deployer tekton uses the filename of the edit group binding: 10-edit-group-rolebinding.yaml": DeployerConfig{ServiceAccount: "pipeline", Namespace: "tekton-ci", ClusterRole: "edit", Filename: editRolebindingFilename},
+		"deployer tekton uses the filename of the quota: 10-quotas.yaml":                              DeployerConfig{ServiceAccount: "pipeline", Namespace: "tekton-ci", ClusterRole: "edit", Filename: quotaFilename},
+		"deployer tekton has an invalid clusterRole, it must be a DNS-1123 label: system:deployer":    DeployerConfig{ServiceAccount: "pipeline", Namespace: "tekton-ci", ClusterRole: "system:deployer"},
+		"deployers relman and tekton use the same filename: 10-jenkins-rolebinding.yaml":              DeployerConfig{ServiceAccount: "pipeline", Namespace: "tekton-ci", ClusterRole: "edit", Filename: jenkinsRolebindinngFilename},
+	}
+	for message, deployer := range badDeployers {
+		badConfig = DefaultConfig()
+		badConfig.Deployers = map[string]DeployerConfig{"relman": DefaultConfig().Deployers["relman"], "tekton": deployer}
+		if _, err := New(badConfig); err == nil || err.Error() != message {
+			t.Errorf("wanted \n%s, \nbut got \n%v \n", message, err)
+		}
+	}
+}
+
+func TestLabelSelector(t *testing.T) {
+	// empty selects everything, and must still be present
+	expectedBytes := []byte(`{"podSelector":{}}`)
+	gotBytes, _ := json.Marshal(specNetwork{})
+	if string(expectedBytes) != string(gotBytes) {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes, gotBytes)
+	}
+
+	selector := labelSelector{
+		MatchLabels: map[string]string{"app": "web"},
+		MatchExpressions: []labelSelectorRequirement{
+			labelSelectorRequirement{Key: "tier", Operator: "In", Values: []string{"frontend", "backend"}},
+			labelSelectorRequirement{Key: "canary", Operator: "DoesNotExist"},
+		},
+	}
+	expectedBytes = []byte(`{"podSelector":{"matchLabels":{"app":"web"},"matchExpressions":[{"key":"tier","operator":"In","values":["frontend","backend"]},{"key":"canary","operator":"DoesNotExist"}]}}`)
+	gotBytes, _ = json.Marshal(specNetwork{PodSelector: selector})
+	if string(expectedBytes) != string(gotBytes) {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes, gotBytes)
+	}
+}
+
+func TestCreateIngressNetworkPolicyObjects(t *testing.T) {
+	expectedBytes := make(map[string][]byte)
+	expectedBytes["10-networkpolicy-allow-same-namespace.yaml"] = []byte(`{"kind":"NetworkPolicy","apiVersion":"networking.k8s.io/v1","metadata":{"name":"allow-same-namespace","namespace":"boogie-test"},"spec":{"podSelector":{},"ingress":[{"from":[{"podSelector":{}}]}],"policyTypes":["Ingress"]}}`)
+	expectedBytes["10-networkpolicy-allow-from-openshift-ingress.yaml"] = []byte(`{"kind":"NetworkPolicy","apiVersion":"networking.k8s.io/v1","metadata":{"name":"allow-from-openshift-ingress","namespace":"boogie-test"},"spec":{"podSelector":{},"ingress":[{"from":[{"namespaceSelector":{"matchLabels":{"network.openshift.io/policy-group":"ingress"}}}]}],"policyTypes":["Ingress"]}}`)
+	expectedBytes["10-networkpolicy-allow-from-openshift-monitoring.yaml"] = []byte(`{"kind":"NetworkPolicy","apiVersion":"networking.k8s.io/v1","metadata":{"name":"allow-from-openshift-monitoring","namespace":"boogie-test"},"spec":{"podSelector":{},"ingress":[{"from":[{"namespaceSelector":{"matchLabels":{"network.openshift.io/policy-group":"monitoring"}}}]}],"policyTypes":["Ingress"]}}`)
+
+	// all of them
+	i := Request{ProjectName: "boogie-test", Environment: "dev", AllowIngress: []string{"same-namespace", "openshift-ingress", "openshift-monitoring"}}
+	fileNames, policies := createIngressNetworkPolicyObjects(&i)
+	if len(fileNames) != 3 {
+		t.Fatalf("wanted \n%d, \nbut got \n%d \n", 3, len(fileNames))
+	}
+	for n, fileName := range fileNames {
+		gotBytes, _ := json.Marshal(policies[n])
+		if string(expectedBytes[fileName]) != string(gotBytes) {
+			t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes[fileName], gotBytes)
+		}
+	}
+
+	// selected, in any order and case
+	d, err := DecodeRequest([]byte(`{"projectname": "boogie-test", "environment": "dev", "allowIngress": ["OpenShift-Monitoring", "same-namespace"]}`))
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	fileNames, _ = createIngressNetworkPolicyObjects(&d)
+	if len(fileNames) != 2 || fileNames[0] != "10-networkpolicy-allow-same-namespace.yaml" || fileNames[1] != "10-networkpolicy-allow-from-openshift-monitoring.yaml" {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", "same-namespace and openshift-monitoring", fileNames)
+	}
+
+	// none at all, whether asked for or not mentioned: they are opt-in
+	for _, request := range []string{
+		`{"projectname": "boogie-test", "environment": "dev", "allowIngress": []}`,
+		`{"projectname": "boogie-test", "environment": "dev"}`,
+	} {
+		d, err = DecodeRequest([]byte(request))
+		if err != nil {
+			t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+		}
+		results, err := Generate(context.Background(), d)
+		if err != nil {
+			t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+		}
+		for _, source := range ingressPolicies {
+			if _, found := findObjectIndex(ingressPolicyFilename(source), resultNames(results)); found {
+				t.Errorf("wanted \n%s, \nbut got \n%v \n", "no allow policies", resultNames(results))
+			}
+		}
+		if _, found := findObjectIndex(networkPolicyFilename, resultNames(results)); !found {
+			t.Errorf("wanted \n%s, \nbut got \n%v \n", "deny-by-default", resultNames(results))
+		}
+	}
+
+	invalid := map[string]string{
+		`["everywhere"]`: "allowIngress everywhere is not one of: same-namespace, openshift-ingress, openshift-monitoring",
+		`["openshift-ingress", "openshift-ingress"]`: "allowIngress is listed more than once: openshift-ingress",
+	}
+	for allow, want := range invalid {
+		_, err = DecodeRequest([]byte(`{"projectname": "boogie-test", "environment": "dev", "allowIngress": ` + allow + `}`))
+		if err == nil || err.Error() != want {
+			t.Errorf("wanted \n%s, \nbut got \n%v \n", want, err)
+		}
+	}
+}
+
+func TestEgress(t *testing.T) {
+	d, err := DecodeRequest([]byte(`{"projectname": "boogie-test", "environment": "dev", "egress": [{"cidrSelector": "10.1.2.3/16"}, {"dnsName": "API.Example.com."}]}`))
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	_, e := createEgressNetworkPolicyObject(&d)
+	expected := []egressRules{
+		{EgressType: "Allow", To: EgressDestination{Cidr: "10.1.0.0/16"}},
+		{EgressType: "Allow", To: EgressDestination{URL: "api.example.com"}},
+		{EgressType: "Deny", To: EgressDestination{Cidr: "0.0.0.0/0"}},
+	}
+	if !reflect.DeepEqual(e.Spec.Egress, expected) {
+		t.Errorf("wanted \n%v, \nbut got \n%v \n", expected, e.Spec.Egress)
+	}
+
+	invalid := map[string]string{
+		`[{}]`: "egress entry must have either a cidrSelector or a dnsName",
+		`[{"cidrSelector": "10.0.0.0/8", "dnsName": "example.com"}]`:       "egress entry must have either a cidrSelector or a dnsName, not both",
+		`[{"cidrSelector": "10.0.0.0"}]`:                                   "egress cidrSelector is not a valid IPv4 CIDR: 10.0.0.0",
+		`[{"cidrSelector": "fd00::/8"}]`:                                   "egress cidrSelector is not a valid IPv4 CIDR: fd00::/8",
+		`[{"dnsName": "*.example.com"}]`:                                   "egress dnsName is not a valid DNS name: *.example.com",
+		`[{"dnsName": "-example.com"}]`:                                    "egress dnsName is not a valid DNS name: -example.com",
+		`[{"dnsName": "example..com"}]`:                                    "egress dnsName is not a valid DNS name: example..com",
+		`[{"cidrSelector": "10.0.0.1/8"}, {"cidrSelector": "10.0.0.0/8"}]`: "egress destination is listed more than once: 10.0.0.0/8",
+	}
+	for egress, message := range invalid {
+		_, err := DecodeRequest([]byte(`{"projectname": "boogie-test", "environment": "dev", "egress": ` + egress + `}`))
+		if err == nil || err.Error() != message {
+			t.Errorf("wanted \n%s, \nbut got \n%v \n", message, err)
+		}
+	}
+
+	// the final deny counts towards OpenShift's limit
+	var destinations []EgressDestination
+	for i := 0; i < maxEgressRules-1; i++ {
+		destinations = append(destinations, EgressDestination{URL: "host" + strconv.Itoa(i) + ".example.com"})
+	}
+	r := Request{ProjectName: "boogie-test", Environment: "dev", Egress: destinations}
+	if _, err := Generate(context.Background(), r); err != nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	r.Egress = append(destinations, EgressDestination{Cidr: "10.0.0.0/8"})
+	_, err = Generate(context.Background(), r)
+	message := "egress allows at most 49 destinations, found: 50"
+	if err == nil || err.Error() != message {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", message, err)
+	}
+}
+
+func TestOptionalRequests(t *testing.T) {
+	d, err := DecodeRequest([]byte(`{"projectname": "boogie-test", "environment": "dev", "optionals": [
+		{"name": "cpu", "count": 2, "request": {"count": 500, "unit": "m"}},
+		{"name": "memory", "count": 1, "unit": "Gi", "request": {"count": 1024, "unit": "Mi"}}
+	]}`))
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	_, q := createLimitsObject(&d)
+	if q.Spec.Hard.CPU != 2 || q.Spec.Hard.RequestsCPU != "500m" || q.Spec.Hard.Memory != "1Gi" || q.Spec.Hard.RequestsMemory != "1Gi" {
+		t.Errorf("wanted \n%s, \nbut got \n%+v \n", "limits 2 and 1Gi, requests 500m and 1Gi", q.Spec.Hard)
+	}
+
+	// the request unit defaults to the limit's
+	d, err = DecodeRequest([]byte(`{"projectname": "boogie-test", "environment": "dev", "optionals": [{"name": "memory", "count": 4, "unit": "Gi", "request": {"count": 2}}]}`))
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	_, q = createLimitsObject(&d)
+	if q.Spec.Hard.RequestsMemory != "2Gi" {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "2Gi", q.Spec.Hard.RequestsMemory)
+	}
+
+	invalid := map[string]string{
+		`{"name": "cpu", "count": 1, "request": {"count": 1001, "unit": "m"}}`:               "cpu request 1001m must not exceed its limit 1",
+		`{"name": "memory", "count": 1, "unit": "G", "request": {"count": 1, "unit": "Gi"}}`: "memory request 1Gi must not exceed its limit 1G",
+		`{"name": "memory", "count": 1, "unit": "Gi", "request": {"count": 1, "unit": "X"}}`: "optional unit entry is invalid: X",
+		`{"name": "volumes", "count": 1, "request": {"count": 1}}`:                           "optional request is only supported for: cpu, memory",
+	}
+	for optional, message := range invalid {
+		_, err := DecodeRequest([]byte(`{"projectname": "boogie-test", "environment": "dev", "optionals": [` + optional + `]}`))
+		if err == nil || err.Error() != message {
+			t.Errorf("wanted \n%s, \nbut got \n%v \n", message, err)
+		}
+	}
+
+	o, err := NewOptional("cpu", 1, "")
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	if _, err := o.WithRequest(2, ""); err == nil || err.Error() != "cpu request 2 must not exceed its limit 1" {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", "cpu request 2 must not exceed its limit 1", err)
+	}
+}
+
+func TestObjectCounts(t *testing.T) {
+	d, err := DecodeRequest([]byte(`{"projectname": "boogie-test", "environment": "dev", "optionals": [
+		{"name": "Pods", "count": 20},
+		{"name": "services.loadbalancers", "count": 0},
+		{"name": "count/routes.route.openshift.io", "count": 10},
+		{"name": "services.nodeports", "quantity": "0"}
+	]}`))
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	// a cap of zero is kept, while services, not being asked for, are left out
+	_, q := createLimitsObject(&d)
+	b, _ := json.Marshal(q.Spec.Hard)
+	expected := `{"pods":20,"services.loadbalancers":0,"services.nodeports":0,"count/routes.route.openshift.io":10}`
+	if string(b) != expected {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expected, string(b))
+	}
+
+	invalid := map[string]string{
+		`{"name": "pods", "count": 201}`:                                                          "request exceeds the ceilings for environment dev: pods requested 201, allowed 200",
+		`{"name": "secrets", "count": 1, "unit": "K"}`:                                            "unit is not allowed for: secrets",
+		`{"name": "pods", "count": -1}`:                                                           "optionals[0] count must not be negative, found: -1",
+		`{"name": "routes", "count": 1}, {"name": "count/routes.route.openshift.io", "count": 2}`: "optional is listed more than once: routes",
+	}
+	for optional, message := range invalid {
+		_, err := DecodeRequest([]byte(`{"projectname": "boogie-test", "environment": "dev", "optionals": [` + optional + `]}`))
+		if err == nil || err.Error() != message {
+			t.Errorf("wanted \n%s, \nbut got \n%v \n", message, err)
+		}
+	}
+
+	// ceilings are configured per environment
+	config, err := LoadConfig(filepath.Join("testdata", "config.yaml"))
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	p, err := New(config)
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	if _, err := p.Decode([]byte(`{"projectname": "boogie-test", "environment": "prod", "optionals": [{"name": "pods", "count": 1000}]}`)); err != nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	_, err = p.Decode([]byte(`{"projectname": "boogie-test", "environment": "prod", "optionals": [{"name": "services.loadbalancers", "count": 1}]}`))
+	message := "request exceeds the ceilings for environment prod: services.loadbalancers requested 1, allowed 0"
+	if err == nil || err.Error() != message {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", message, err)
+	}
+	if _, err := New(&Config{Environments: map[string]EnvironmentConfig{"dev": EnvironmentConfig{Ceilings: map[string]oQuantity{"bogus": oQuantity{"1"}}}}}); err == nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "an error", "nil")
+	}
+}
+
+func TestStorageClasses(t *testing.T) {
+	config, err := LoadConfig(filepath.Join("testdata", "config.yaml"))
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	p, err := New(config)
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+
+	d, err := p.Decode([]byte(`{"projectname": "boogie-test", "environment": "test", "optionals": [
+		{"name": "storage", "count": 100, "unit": "Gi"},
+		{"name": "volumes", "count": 10},
+		{"name": "storage", "count": 20, "unit": "Gi", "storageClass": "Fast-SSD"},
+		{"name": "volumes", "count": 2, "storageClass": "fast-ssd"},
+		{"name": "volumes", "count": 1, "storageClass": "backup"}
+	]}`))
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	_, q := createLimitsObject(&d)
+	b, err := json.Marshal(q.Spec.Hard)
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	expected := `{"persistentvolumeclaims":10,"requests.storage":"100Gi",` +
+		`"backup.storageclass.storage.k8s.io/persistentvolumeclaims":1,` +
+		`"fast-ssd.storageclass.storage.k8s.io/requests.storage":"20Gi",` +
+		`"fast-ssd.storageclass.storage.k8s.io/persistentvolumeclaims":2}`
+	if string(b) != expected {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expected, string(b))
+	}
+
+	// a quota with only storage classes is not empty
+	d, err = p.Decode([]byte(`{"projectname": "boogie-test", "environment": "test", "optionals": [{"name": "volumes", "count": 1, "storageClass": "standard"}]}`))
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	_, q = createLimitsObject(&d)
+	b, _ = json.Marshal(q.Spec.Hard)
+	if isEmptyObject(q) || string(b) != `{"standard.storageclass.storage.k8s.io/persistentvolumeclaims":1}` {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "standard persistentvolumeclaims", string(b))
+	}
+
+	invalid := map[string]string{
+		`{"name": "volumes", "count": 1, "storageClass": "slow"}`:     "storageClass slow is not one of: fast-ssd, standard, backup",
+		`{"name": "cpu", "count": 1, "storageClass": "standard"}`:     "optional storageClass is only supported for: storage, volumes",
+		`{"name": "storage", "count": 1, "storageClass": "standard"}`: "invalid or missing unit for: storage",
+	}
+	for optional, message := range invalid {
+		_, err := p.Decode([]byte(`{"projectname": "boogie-test", "environment": "test", "optionals": [` + optional + `]}`))
+		if err == nil || err.Error() != message {
+			t.Errorf("wanted \n%s, \nbut got \n%v \n", message, err)
+		}
+	}
+
+	// the storage classes are those of the provisioner that generates the request, not the default configuration
+	o, _ := NewOptional("volumes", 1, "")
+	if o, err = o.WithStorageClass("Slow"); err != nil || o.StorageClass != "slow" {
+		t.Errorf("wanted \n%s, \nbut got \n%s %v \n", "slow", o.StorageClass, err)
+	}
+	o, _ = NewOptional("cpu", 1, "")
+	if _, err = o.WithStorageClass("standard"); err == nil || err.Error() != "optional storageClass is only supported for: storage, volumes" {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", "optional storageClass is only supported for: storage, volumes", err)
+	}
+	config = DefaultConfig()
+	config.StorageClasses = nil
+	if p, err = New(config); err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	message := "storageClass standard is not allowed, no storage classes are configured"
+	_, err = p.Decode([]byte(`{"projectname": "boogie-test", "environment": "test", "optionals": [{"name": "volumes", "count": 1, "storageClass": "standard"}]}`))
+	if err == nil || err.Error() != message {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", message, err)
+	}
+}
+
+func TestCreateLimitRangeObject(t *testing.T) {
+	// derived from the quota
+	d, err := DecodeRequest([]byte(`{"projectname": "boogie-test", "environment": "dev", "optionals": [
+		{"name": "cpu", "count": 2},
+		{"name": "memory", "count": 3, "unit": "Gi"}
+	]}`))
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	fileName, l := createLimitRangeObject(&d, DefaultConfig())
+	gotBytes, _ := json.Marshal(l)
+	expected := `{"kind":"LimitRange","apiVersion":"v1","metadata":{"name":"default-limits","namespace":"boogie-test"},"spec":{"limits":[{"type":"Container",` +
+		`"default":{"cpu":"500m","memory":"768Mi"},"defaultRequest":{"cpu":"250m","memory":"384Mi"},"max":{"cpu":"2","memory":"3Gi"}}]}}`
+	if fileName != limitRangeFilename || string(gotBytes) != expected {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expected, gotBytes)
+	}
+
+	// defaults never exceed the maximum, even for limits too small to split
+	d, err = DecodeRequest([]byte(`{"projectname": "boogie-test", "environment": "dev", "optionals": [
+		{"name": "cpu", "quantity": "1m"},
+		{"name": "memory", "quantity": "1000"}
+	]}`))
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	_, l = createLimitRangeObject(&d, DefaultConfig())
+	gotBytes, _ = json.Marshal(l.Spec.Limits)
+	expected = `[{"type":"Container","default":{"cpu":"1m","memory":"1k"},"defaultRequest":{"cpu":"1m","memory":"1k"},"max":{"cpu":"1m","memory":"1k"}}]`
+	if string(gotBytes) != expected {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expected, gotBytes)
+	}
+
+	// nothing to default without cpu or memory limits
+	d, err = DecodeRequest([]byte(`{"projectname": "boogie-test", "environment": "dev", "optionals": [{"name": "volumes", "count": 2}]}`))
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	if _, l := createLimitRangeObject(&d, DefaultConfig()); !isEmptyObject(l) {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", "no LimitRange", l)
+	}
+
+	// configured per environment
+	limits := &LimitRangeConfig{
+		Default: map[string]string{"cpu": "1", "memory": "1Gi"},
+		Max:     map[string]string{"cpu": "2", "memory": "2Gi"},
+		Min:     map[string]string{"cpu": "10m"},
+	}
+	p, err := New(&Config{Environments: map[string]EnvironmentConfig{"dev": EnvironmentConfig{LimitRange: limits}}})
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	_, l = createLimitRangeObject(&d, p.config)
+	if len(l.Spec.Limits) != 1 || !reflect.DeepEqual(l.Spec.Limits[0].Max, limits.Max) || l.Spec.Limits[0].Min["cpu"] != "10m" {
+		t.Errorf("wanted \n%v, \nbut got \n%v \n", limits, l.Spec.Limits)
+	}
+
+	invalid := map[string]*LimitRangeConfig{
+		"environment dev: limitRange default cpu must not be less than defaultRequest":       &LimitRangeConfig{Default: map[string]string{"cpu": "1"}, DefaultRequest: map[string]string{"cpu": "2"}},
+		"environment dev: limitRange max: memory quantity must be in bytes, found suffix: m": &LimitRangeConfig{Max: map[string]string{"memory": "2m"}},
+		"environment dev: limitRange min is not one of: cpu, memory, found: pods":            &LimitRangeConfig{Min: map[string]string{"pods": "1"}},
+	}
+	for message, limits := range invalid {
+		_, err := New(&Config{Environments: map[string]EnvironmentConfig{"dev": EnvironmentConfig{LimitRange: limits}}})
+		if err == nil || err.Error() != message {
+			t.Errorf("wanted \n%s, \nbut got \n%v \n", message, err)
+		}
+	}
+}
+
+func TestParseQuantity(t *testing.T) {
+	canonical := map[string]string{
+		"1.5Gi":  "1536Mi",
+		"2000Mi": "2000Mi",
+		"2048Mi": "2Gi",
+		"0.5":    "500m",
+		"2000m":  "2",
+		"1e3":    "1k",
+		"1E-3":   "1m",
+		"1.5e3":  "1500",
+		"+3":     "3",
+		"0.5Ki":  "512",
+		".5":     "500m",
+		"10G":    "10G",
+		"1E":     "1E",
+		"2Ei":    "2Ei",
+		"0Gi":    "0",
+	}
+	for s, expected := range canonical {
+		q, err := parseQuantity(s)
+		if err != nil {
+			t.Errorf("wanted \n%s, \nbut got \n%s \n", expected, err.Error())
+			continue
+		}
+		if q.String() != expected {
+			t.Errorf("wanted \n%s, \nbut got \n%s \n", expected, q.String())
+		}
+	}
+
+	for _, s := range []string{"", "Gi", "1.5.5", "1K", "1gi", "--1", "1-", "1e", "1e+-3", "1e99", "1 Gi", "1Gib"} {
+		if _, err := parseQuantity(s); err == nil || err.Error() != "invalid quantity: "+s {
+			t.Errorf("wanted \n%s, \nbut got \n%v \n", "invalid quantity: "+s, err)
+		}
+	}
+
+	invalid := map[string]string{
+		`{"name": "cpu", "quantity": "1Gi"}`:                                      "cpu quantity must not use a binary suffix: 1Gi",
+		`{"name": "cpu", "quantity": "0.0005"}`:                                   "cpu quantity must be a whole number of millicores: 500u",
+		`{"name": "cpu", "quantity": "-1"}`:                                       "cpu quantity must not be negative: -1",
+		`{"name": "memory", "quantity": "500m"}`:                                  "memory quantity must be in bytes, found suffix: m",
+		`{"name": "memory", "count": 500, "unit": "m"}`:                           "memory quantity must be in bytes, found suffix: m",
+		`{"name": "memory", "quantity": "1000m"}`:                                 "memory quantity must be in bytes, found suffix: m",
+		`{"name": "storage", "quantity": "1000000000n"}`:                          "storage quantity must be in bytes, found suffix: n",
+		`{"name": "memory", "quantity": "1000e-3"}`:                               "memory quantity must be in bytes, found suffix: e-3",
+		`{"name": "memory", "quantity": "0.5"}`:                                   "memory quantity must be a whole number of bytes: 500m",
+		`{"name": "cpu", "count": 1, "unit": "Gi"}`:                               "cpu quantity must not use a binary suffix: 1Gi",
+		`{"name": "pods", "quantity": "1.5"}`:                                     "pods quantity must be a whole number: 1500m",
+		`{"name": "memory", "count": 1, "quantity": "1Gi"}`:                       "optional memory takes either a count and unit, or a quantity, not both",
+		`{"name": "memory", "quantity": "1Gb"}`:                                   "invalid quantity: 1Gb",
+		`{"name": "memory", "quantity": "1Gi", "request": {"quantity": "1.5Gi"}}`: "memory request 1536Mi must not exceed its limit 1Gi",
+	}
+	for optional, message := range invalid {
+		_, err := DecodeRequest([]byte(`{"projectname": "boogie-test", "environment": "dev", "optionals": [` + optional + `]}`))
+		if err == nil || err.Error() != message {
+			t.Errorf("wanted \n%s, \nbut got \n%v \n", message, err)
+		}
+	}
+
+	d, err := DecodeRequest([]byte(`{"projectname": "boogie-test", "environment": "dev", "optionals": [
+		{"name": "cpu", "quantity": 1.5, "request": {"quantity": "250m"}},
+		{"name": "memory", "quantity": "1.5Gi", "request": {"quantity": "1e9"}},
+		{"name": "storage", "quantity": "100G"},
+		{"name": "pods", "quantity": "1e2"}
+	]}`))
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	_, q := createLimitsObject(&d)
+	b, _ := json.Marshal(q.Spec.Hard)
+	expected := `{"limits.cpu":"1500m","limits.memory":"1536Mi","requests.cpu":"250m","requests.memory":"1G","requests.storage":"100G","pods":100}`
+	if string(b) != expected {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expected, string(b))
+	}
+
+	o, err := NewQuantityOptional("memory", "0.5Gi")
+	if err != nil || o.limitString() != "512Mi" {
+		t.Errorf("wanted \n%s, \nbut got \n%s, %v \n", "512Mi", o.limitString(), err)
+	}
+}
+
+func TestSizes(t *testing.T) {
+	// explicit optionals win over the size, which wins over the environment
+	config := DefaultConfig()
+	config.Environments["dev"] = EnvironmentConfig{Optionals: []Optional{
+		Optional{Name: oName{"pods"}, Count: oCount{10}},
+		Optional{Name: oName{"cpu"}, Count: oCount{8}},
+	}}
+	p, err := New(config)
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+
+	d, err := p.Decode([]byte(`{"projectname": "boogie-test", "environment": "dev", "size": "Medium", "optionals": [{"name": "memory", "quantity": "6Gi"}]}`))
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	_, q := createLimitsObject(&d)
+	b, _ := json.Marshal(q.Spec.Hard)
+	expected := `{"limits.cpu":2,"limits.memory":"6Gi","persistentvolumeclaims":5,"requests.storage":"50Gi","pods":10}`
+	if string(b) != expected {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expected, string(b))
+	}
+	if d.Size != "medium" || strings.Join(d.FromSize(), ", ") != "cpu: 2, volumes: 5, storage: 50Gi" {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", "cpu: 2, volumes: 5, storage: 50Gi", d.FromSize())
+	}
+
+	// generating validates again, which must not lose track of what came from the size
+	if _, err := p.Generate(context.Background(), d); err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	if len(d.FromSize()) != 3 {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", "3 values from the size", d.FromSize())
+	}
+
+	_, err = p.Decode([]byte(`{"projectname": "boogie-test", "environment": "dev", "size": "huge"}`))
+	message := "size huge is not one of: large, medium, small"
+	if err == nil || err.Error() != message {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", message, err)
+	}
+
+	// sizes may override the LimitRange
+	config = DefaultConfig()
+	config.Sizes = map[string]SizeConfig{"tiny": SizeConfig{
+		Optionals:  []Optional{Optional{Name: oName{"cpu"}, Quantity: oQuantity{"500m"}}},
+		LimitRange: &LimitRangeConfig{Max: map[string]string{"cpu": "250m"}},
+	}}
+	if p, err = New(config); err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	d, err = p.Decode([]byte(`{"projectname": "boogie-test", "environment": "dev", "size": "tiny"}`))
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	_, l := createLimitRangeObject(&d, p.config)
+	if len(l.Spec.Limits) != 1 || l.Spec.Limits[0].Max["cpu"] != "250m" || l.Spec.Limits[0].Default != nil {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", "the size's LimitRange", l.Spec.Limits)
+	}
+	if strings.Join(d.FromSize(), ", ") != "cpu: 500m, limitRange" {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", "cpu: 500m, limitRange", d.FromSize())
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	// every violation is reported, not just the first
+	request := `{"projectname": "boogie-test", "environment": "dev", "optionals": [
+		{"name": "cpu", "quantity": "500", "request": {"quantity": "40"}},
+		{"name": "memory", "quantity": "1Gi"},
+		{"name": "pods", "count": 300}
+	]`
+	_, err := DecodeRequest([]byte(request + `}`))
+	message := "request exceeds the ceilings for environment dev: cpu requested 500, allowed 32; cpu request requested 40, allowed 32; pods requested 300, allowed 200"
+	if err == nil || err.Error() != message {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", message, err)
+	}
+	if policyErr, ok := err.(*PolicyError); !ok || len(policyErr.Violations) != 3 || policyErr.Violations[2] != (PolicyViolation{Key: "pods", Path: "optionals[2]", Requested: "300", Allowed: "200"}) {
+		t.Errorf("wanted \n%s, \nbut got \n%#v \n", "a PolicyError with 3 violations", err)
+	}
+
+	// an override lets it through, and is recorded on the Project
+	d, err := DecodeRequest([]byte(request + `, "override": {"approver": " jane.doe ", "reason": "load tests"}}`))
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	if len(d.Violations()) != 3 {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", "3 violations", d.Violations())
+	}
+	_, project := createProjectObject(&d)
+	expected := map[string]string{
+		annotationViolations: "cpu requested 500, allowed 32; cpu request requested 40, allowed 32; pods requested 300, allowed 200",
+		annotationApprover:   "jane.doe",
+		annotationReason:     "load tests",
+	}
+	if !reflect.DeepEqual(project.Metadata.Annotations, expected) {
+		t.Errorf("wanted \n%v, \nbut got \n%v \n", expected, project.Metadata.Annotations)
+	}
+
+	_, err = DecodeRequest([]byte(request + `, "override": {"reason": "load tests"}}`))
+	if err == nil || err.Error() != "override needs an approver" {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", "override needs an approver", err)
+	}
+
+	// requests within the ceilings have nothing to record
+	d, err = DecodeRequest([]byte(`{"projectname": "boogie-test", "environment": "dev", "optionals": [{"name": "cpu", "count": 2}]}`))
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	if _, project := createProjectObject(&d); d.Violations() != nil || project.Metadata.Annotations != nil {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", "no annotations", project.Metadata.Annotations)
+	}
+
+	// environments may only flag violations
+	config := DefaultConfig()
+	config.Environments["dev"] = EnvironmentConfig{Enforcement: "flag", Ceilings: map[string]oQuantity{"cpu": oQuantity{"4"}}}
+	p, err := New(config)
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	d, err = p.Decode([]byte(`{"projectname": "boogie-test", "environment": "dev", "optionals": [{"name": "cpu", "count": 8}]}`))
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	if _, project := createProjectObject(&d); project.Metadata.Annotations[annotationViolations] != "cpu requested 8, allowed 4" {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", "cpu requested 8, allowed 4", project.Metadata.Annotations)
+	}
+
+	config = DefaultConfig()
+	config.Environments["dev"] = EnvironmentConfig{Enforcement: "warn"}
+	if _, err := New(config); err == nil || err.Error() != "environment dev: enforcement is not one of: reject, flag, found: warn" {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", "environment dev: enforcement is not one of: reject, flag, found: warn", err)
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	// every problem is reported at once, each with where it is and which rule it breaks
+	request := `{"projectname": "boogie_test", "environment": "dev", "deployers": ["nobody"], "size": "huge",
+		"egress": [{"cidrSelector": "10.0.0.0/33"}],
+		"optionals": [
+			{"name": "cpu", "count": 1, "unit": "Gb"},
+			{"name": "bogus", "count": 1},
+			{"name": "pods", "count": 5, "unit": "Gi"},
+			{"name": "memory", "quantity": "1Gi", "request": {"quantity": "2Gi"}}
+		]}`
+	_, err := DecodeRequest([]byte(request))
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("wanted \n%s, \nbut got \n%#v \n", "ValidationErrors", err)
+	}
+	wanted := []ValidationError{
+		{Field: "projectname", Path: "projectname", Value: "boogie_test", Rule: "no-underscores", Msg: "data contains illegal underscores"},
+		{Field: "deployers", Path: "deployers[0]", Value: "nobody", Rule: "enum", Msg: "deployer nobody is not one of: " + strings.Join(DefaultConfig().deployerNames(), ", ")},
+		{Field: "egress.cidrSelector", Path: "egress[0].cidrSelector", Value: "10.0.0.0/33", Rule: "cidr", Msg: "egress cidrSelector is not a valid IPv4 CIDR: 10.0.0.0/33"},
+		{Field: "size", Path: "size", Value: "huge", Rule: "enum", Msg: "size huge is not one of: large, medium, small"},
+		{Field: "optionals.unit", Path: "optionals[0].unit", Value: "Gb", Rule: "enum", Msg: "optional unit entry is invalid: Gb"},
+		{Field: "optionals.name", Path: "optionals[1].name", Value: "bogus", Rule: "enum", Msg: "optional name entry is invalid: bogus"},
+		{Field: "optionals.unit", Path: "optionals[2].unit", Value: "Gi", Rule: "unit-not-allowed", Msg: "unit is not allowed for: pods"},
+		{Field: "optionals.request", Path: "optionals[3].request", Value: "2Gi", Rule: "max", Msg: "memory request 2Gi must not exceed its limit 1Gi"},
+	}
+	if len(errs) != len(wanted) {
+		t.Fatalf("wanted \n%d errors, \nbut got \n%s \n", len(wanted), errs.Error())
+	}
+	for i := range wanted {
+		if *errs[i] != wanted[i] {
+			t.Errorf("wanted \n%#v, \nbut got \n%#v \n", wanted[i], *errs[i])
+		}
+	}
+
+	// a single problem is still a single error
+	_, err = DecodeRequest([]byte(`{"projectname": "boogie-test", "environment": "dev", "optionals": [{"name": "cpu", "count": 1, "unit": "Gb"}]}`))
+	if _, ok := err.(*ValidationError); !ok {
+		t.Errorf("wanted \n%s, \nbut got \n%#v \n", "a *ValidationError", err)
+	}
+
+	document := string(ErrorDocument(errs[4:5]))
+	wantedDocument := `{
+  "errors": [
+    {
+      "path": "optionals[0].unit",
+      "value": "Gb",
+      "rule": "enum",
+      "message": "optional unit entry is invalid: Gb"
+    }
+  ]
+}`
+	if document != wantedDocument {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", wantedDocument, document)
+	}
+	document = string(ErrorDocument(&PolicyError{Environment: "dev", Violations: []PolicyViolation{{Key: "pods", Path: "optionals[2]", Requested: "300", Allowed: "200"}}}))
+	if !strings.Contains(document, `"path": "optionals[2]"`) || !strings.Contains(document, `"rule": "ceiling"`) {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "the violation as a ceiling error", document)
+	}
+
+	// values of the wrong type are reported where they are, along with everything else
+	_, err = DecodeRequest([]byte(`{"projectname": "bad name", "environment": "prd", "optionals": [{"name": "cpu", "count": "two"}], "egress": {}}`))
+	errs, ok = err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("wanted \n%s, \nbut got \n%#v \n", "ValidationErrors", err)
+	}
+	wanted = []ValidationError{
+		{Field: "optionals.count", Path: "optionals[0].count", Value: "two", Rule: "type", Msg: `optionals[0].count must be an integer, found: "two"`},
+		{Field: "egress", Path: "egress", Rule: "type", Msg: "egress must be a list, found: an object"},
+		{Field: "projectname", Path: "projectname", Value: "bad name", Rule: "no-spaces", Msg: "data contains illegal spaces"},
+		{Field: "environment", Path: "environment", Value: "prd", Rule: "enum", Msg: "environment prd is not one of: acc, dev, prod, test"},
+	}
+	if len(errs) != len(wanted) {
+		t.Fatalf("wanted \n%d errors, \nbut got \n%s \n", len(wanted), errs.Error())
+	}
+	for i := range wanted {
+		if *errs[i] != wanted[i] {
+			t.Errorf("wanted \n%#v, \nbut got \n%#v \n", wanted[i], *errs[i])
+		}
+	}
+	_, err = DecodeRequest([]byte(`{"apiVersion": "provisioner/v2", "kind": "ProjectRequest", "metadata": {"name": "boogie-test"},
+		"spec": {"environment": "dev", "resources": [{"name": "cpu", "limit": true}]}}`))
+	if e, ok := err.(*ValidationError); !ok || e.Path != "spec.resources[0].limit" || e.Rule != "type" {
+		t.Errorf("wanted \n%s, \nbut got \n%#v \n", "a type error for spec.resources[0].limit", err)
+	}
+}
+
+func TestStrictDecoding(t *testing.T) {
+	request := `{"projectName": "boogie-test", "environment": "dev", "optionals": [
+		{"name": "cpu", "count": 0},
+		{"name": "memory", "count": 1, "unit": "Gi", "Request": {"count": 512, "unit": "Mi"}},
+		{"name": "CPU", "count": 2},
+		{"name": "pods", "count": -5},
+		{"name": "storage", "unit": "Gi"},
+		{"name": "memory", "count": 1, "unit": "Gi", "request": {"count": 0, "unit": "Mi"}}
+	]}`
+	_, err := DecodeRequest([]byte(request))
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("wanted \n%s, \nbut got \n%#v \n", "ValidationErrors", err)
+	}
+	wanted := []string{
+		"projectName unknown-field",
+		"optionals[0].count min",
+		"optionals[1].Request unknown-field",
+		"optionals[3].count min",
+		"optionals[4].unit misplaced-unit",
+		"optionals[5].request.count min",
+		"optionals[2].name unique",
+		"optionals[5].name unique",
+	}
+	var got []string
+	for _, err := range errs {
+		got = append(got, err.Path+" "+err.Rule)
+	}
+	if strings.Join(got, "\n") != strings.Join(wanted, "\n") {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", strings.Join(wanted, "\n"), strings.Join(got, "\n"))
+	}
+
+	// the same storage optional for different storage classes is fine
+	config := DefaultConfig()
+	config.StorageClasses = []string{"fast-ssd", "backup"}
+	p, _ := New(config)
+	_, err = p.Decode([]byte(`{"projectname": "boogie-test", "environment": "dev", "optionals": [
+		{"name": "storage", "count": 10, "unit": "Gi", "storageClass": "fast-ssd"},
+		{"name": "storage", "count": 20, "unit": "Gi", "storageClass": "backup"}
+	]}`))
+	if err != nil {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+
+	// lenient decoding accepts what older versions did
+	config = DefaultConfig()
+	config.Lenient = true
+	p, _ = New(config)
+	d, err := p.Decode([]byte(`{"projectname": "boogie-test", "environment": "dev", "role": "developer", "optionals": [
+		{"name": "cpu", "count": 2},
+		{"name": "cpu", "count": 4},
+		{"name": "volumes", "count": 0}
+	]}`))
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	if d.getOptional("cpu").Count.int != 2 {
+		t.Errorf("wanted \n%d, \nbut got \n%d \n", 2, d.getOptional("cpu").Count.int)
+	}
+}
+
+func schemaAccepts(s *jsonSchema, value interface{}) bool {
+	// a minimal validator, for just the keywords that jsonSchema has
+	switch t := s.Type.(type) {
+	case string:
+		if !schemaType(t, value) {
+			return false
+		}
+	case []interface{}:
+		matched := false
+		for _, one := range t {
+			matched = matched || schemaType(fmt.Sprint(one), value)
+		}
+		if !matched {
+			return false
+		}
+	}
+	if s.Enum != nil && !inList(fmt.Sprint(value), s.Enum) {
+		return false
+	}
+	switch v := value.(type) {
+	case string:
+		if s.Pattern != "" && !regexp.MustCompile(s.Pattern).MatchString(v) {
+			return false
+		}
+		if s.MaxLength != nil && len(v) > *s.MaxLength {
+			return false
+		}
+	case float64:
+		if s.Minimum != nil && v < float64(*s.Minimum) {
+			return false
+		}
+	case []interface{}:
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			return false
+		}
+		seen := make(map[string]bool)
+		for _, item := range v {
+			if s.UniqueItems && seen[fmt.Sprint(item)] {
+				return false
+			}
+			seen[fmt.Sprint(item)] = true
+			if s.Items != nil && !schemaAccepts(s.Items, item) {
+				return false
+			}
+		}
+	case map[string]interface{}:
+		for _, key := range s.Required {
+			if _, ok := v[key]; !ok {
+				return false
+			}
+		}
+		for key, item := range v {
+			if s.PropertyNames != nil && !schemaAccepts(s.PropertyNames, key) {
+				return false
+			}
+			for pattern, property := range s.PatternProperties {
+				if regexp.MustCompile(pattern).MatchString(key) && !schemaAccepts(property, item) {
+					return false
+				}
+			}
+			property, ok := s.Properties[key]
+			if !ok && s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				return false
+			}
+			if ok && !schemaAccepts(property, item) {
+				return false
+			}
+			if _, ok := s.Dependencies[key]; ok && !schemaAccepts(required(s.Dependencies[key]...), v) {
+				return false
+			}
+		}
+	}
+	for _, sub := range s.AllOf {
+		if !schemaAccepts(sub, value) {
+			return false
+		}
+	}
+	if s.AnyOf != nil {
+		matched := false
+		for _, sub := range s.AnyOf {
+			matched = matched || schemaAccepts(sub, value)
+		}
+		if !matched {
+			return false
+		}
+	}
+	if s.OneOf != nil {
+		matched := 0
+		for _, sub := range s.OneOf {
+			if schemaAccepts(sub, value) {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return false
+		}
+	}
+	if s.Not != nil && schemaAccepts(s.Not, value) {
+		return false
+	}
+	if s.If != nil {
+		if schemaAccepts(s.If, value) {
+			return s.Then == nil || schemaAccepts(s.Then, value)
+		}
+		return s.Else == nil || schemaAccepts(s.Else, value)
+	}
+	return true
+}
+
+func schemaType(t string, value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		return t == "string"
+	case float64:
+		return t == "number" || (t == "integer" && v == float64(int64(v)))
+	case bool:
+		return t == "boolean"
+	case []interface{}:
+		return t == "array"
+	case map[string]interface{}:
+		return t == "object"
+	}
+	return t == "null"
+}
+
+func TestSchema(t *testing.T) {
+	// the decoder and the schema agree on which requests are valid
+	config, err := LoadConfig("testdata/config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := New(config)
+
+	valid := []string{
+		`{"projectname": "boogie-test", "environment": "dev"}`,
+		`{"projectname": "boogie-test", "environment": "test", "deployers": ["relman"], "allowIngress": ["same-namespace"],
+			"size": "small", "override": {"approver": "jane.doe", "reason": "load tests"},
+			"egress": [{"cidrSelector": "10.20.0.0/16"}, {"dnsName": "registry.example.com"}],
+			"optionals": [
+				{"name": "cpu", "count": 2, "request": {"count": 500, "unit": "m"}},
+				{"name": "memory", "count": 1, "unit": "Gi", "request": {"quantity": "512Mi"}},
+				{"name": "storage", "quantity": "10Gi", "storageClass": "fast-ssd"},
+				{"name": "volumes", "count": 4},
+				{"name": "pods", "count": 10}
+			]}`,
+		`{"projectname": "boogie-test", "environment": "dev", "optionals": [{"name": "cpu", "quantity": 0.5}]}`,
+		`{"projectname": "b", "environment": "dev", "optionals": [{"name": "memory", "quantity": "1Gi", "request": {"count": 512, "unit": "Mi"}}]}`,
+		`{"projectname": "boogie-test", "environment": "prod", "optionals": [{"name": "services.loadbalancers", "count": 0}, {"name": "count/routes.route.openshift.io", "count": 5}]}`,
+		`{"apiVersion": "provisioner/v1", "kind": "ProjectRequest", "projectname": "boogie-test", "environment": "dev"}`,
+		`{"apiVersion": "provisioner/v2", "kind": "ProjectRequest", "metadata": {"name": "boogie-test"}, "spec": {"environment": "dev"}}`,
+		`{"apiVersion": "provisioner/v2", "kind": "ProjectRequest",
+			"metadata": {"name": "boogie-test", "labels": {"team": "payments", "example.com/cost-center": "A_12.3"}},
+			"spec": {"environment": "test", "size": "small", "deployers": [], "override": {"approver": "jane.doe"},
+				"resources": [
+					{"name": "cpu", "limit": "2", "request": "500m"},
+					{"name": "memory", "limit": "4Gi"},
+					{"name": "storage", "limit": "100Gi", "storageClass": "backup"},
+					{"name": "pods", "limit": 20}
+				],
+				"network": {"allowIngress": [], "egress": [{"cidrSelector": "10.20.0.0/16"}]}
+			}}`,
+	}
+	invalid := []string{
+		`{"projectname": "boogie-test"}`,
+		`{"projectname": "boogie_test", "environment": "dev"}`,
+		`{"projectname": "-boogie", "environment": "dev"}`,
+		`{"projectname": "boogie-test", "environment": "staging"}`,
+		`{"projectname": "boogie-test", "environment": "dev", "role": "developer"}`,
+		`{"projectName": "boogie-test", "environment": "dev"}`,
+		`{"projectname": "boogie-test", "environment": "dev", "optionals": {}}`,
+		`{"projectname": "boogie-test", "environment": "dev", "optionals": [{"name": "gpu", "count": 1}]}`,
+		`{"projectname": "boogie-test", "environment": "dev", "optionals": [{"name": "memory", "count": 1, "unit": "Gb"}]}`,
+		`{"projectname": "boogie-test", "environment": "dev", "optionals": [{"name": "memory", "count": 1}]}`,
+		`{"projectname": "boogie-test", "environment": "dev", "optionals": [{"name": "pods", "count": 1, "unit": "k"}]}`,
+		`{"projectname": "boogie-test", "environment": "dev", "optionals": [{"name": "cpu", "count": 1, "quantity": "1"}]}`,
+		`{"projectname": "boogie-test", "environment": "dev", "optionals": [{"name": "cpu", "count": 0}]}`,
+		`{"projectname": "boogie-test", "environment": "dev", "optionals": [{"name": "cpu", "unit": "m"}]}`,
+		`{"projectname": "boogie-test", "environment": "dev", "optionals": [{"name": "services.nodeports", "count": -1}]}`,
+		`{"projectname": "boogie-test", "environment": "dev", "optionals": [{"name": "memory", "count": 1, "unit": "Gi", "request": {"count": 0, "unit": "Gi"}}]}`,
+		`{"projectname": "boogie-test", "environment": "dev", "optionals": [{"name": "cpu", "quantity": "-1"}]}`,
+		`{"projectname": "boogie-test", "environment": "dev", "optionals": [{"name": "memory", "quantity": "1.5Zi"}]}`,
+		`{"projectname": "boogie-test", "environment": "dev", "optionals": [{"name": "memory", "quantity": true}]}`,
+		`{"projectname": "boogie-test", "environment": "dev", "optionals": [{"name": "pods", "count": 5, "request": {"count": 2}}]}`,
+		`{"projectname": "boogie-test", "environment": "dev", "optionals": [{"name": "memory", "quantity": "1Gi", "request": {"count": 512}}]}`,
+		`{"projectname": "boogie-test", "environment": "dev", "optionals": [{"name": "storage", "count": 1, "unit": "Gi", "storageClass": "slow"}]}`,
+		`{"projectname": "boogie-test", "environment": "dev", "egress": [{"cidrSelector": "10.0.0.0/8", "dnsName": "example.com"}]}`,
+		`{"projectname": "boogie-test", "environment": "dev", "egress": [{"cidrSelector": "10.0.0.0/33"}]}`,
+		`{"projectname": "boogie-test", "environment": "dev", "egress": [{"dnsName": "-example.com"}]}`,
+		`{"projectname": "boogie-test", "environment": "dev", "deployers": ["nobody"]}`,
+		`{"projectname": "boogie-test", "environment": "dev", "deployers": ["relman", "relman"]}`,
+		`{"projectname": "boogie-test", "environment": "dev", "allowIngress": ["everything"]}`,
+		`{"projectname": "boogie-test", "environment": "dev", "size": "huge"}`,
+		`{"projectname": "boogie-test", "environment": "dev", "override": {"reason": "load tests"}}`,
+		`{"projectname": "boogie-test", "environment": "dev", "override": {"approver": "  "}}`,
+		`{"apiVersion": "provisioner/v3", "kind": "ProjectRequest", "projectname": "boogie-test", "environment": "dev"}`,
+		`{"apiVersion": "provisioner/v1", "kind": "Project", "projectname": "boogie-test", "environment": "dev"}`,
+		`{"apiVersion": "provisioner/v1", "projectname": "a", "environment": "dev"}`,
+		`{"apiVersion": "provisioner/v2", "metadata": {"name": "boogie-test"}, "spec": {"environment": "dev"}}`,
+		`{"apiVersion": "provisioner/v2", "kind": "ProjectRequest", "projectname": "boogie-test", "environment": "dev"}`,
+		`{"apiVersion": "provisioner/v2", "kind": "ProjectRequest", "metadata": {"name": "boogie-test"}, "spec": {"environment": "dev", "optionals": []}}`,
+		`{"apiVersion": "provisioner/v2", "kind": "ProjectRequest", "metadata": {"name": "boogie-test", "labels": {"-team": "x"}}, "spec": {"environment": "dev"}}`,
+		`{"apiVersion": "provisioner/v2", "kind": "ProjectRequest", "metadata": {"name": "boogie-test", "labels": {"team": "a b"}}, "spec": {"environment": "dev"}}`,
+		`{"apiVersion": "provisioner/v2", "kind": "ProjectRequest", "metadata": {"name": "boogie-test"}, "spec": {"environment": "dev", "resources": [{"name": "cpu"}]}}`,
+		`{"apiVersion": "provisioner/v2", "kind": "ProjectRequest", "metadata": {"name": "boogie-test"}, "spec": {"environment": "dev", "resources": [{"name": "cpu", "count": 2}]}}`,
+		`{"apiVersion": "provisioner/v2", "kind": "ProjectRequest", "metadata": {"name": "boogie-test"}, "spec": {"environment": "dev", "resources": [{"name": "pods", "limit": "5", "request": "2"}]}}`,
+		`{"apiVersion": "provisioner/v2", "kind": "ProjectRequest", "metadata": {"name": "boogie-test"}, "spec": {"environment": "dev", "network": {"egress": [{"cidrSelector": "10.0.0.0/33"}]}}}`,
+	}
+
+	var schema jsonSchema
+	if err := json.Unmarshal(p.Schema(), &schema); err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "a valid json document", err.Error())
+	}
+	// the published document, rather than requestSchema, so that the test covers its serialization too
+	check := func(payloads []string, wanted bool) {
+		for _, payload := range payloads {
+			var value interface{}
+			if err := json.Unmarshal([]byte(payload), &value); err != nil {
+				t.Fatal(err)
+			}
+			_, err := p.Decode([]byte(payload))
+			if (err == nil) != wanted {
+				t.Errorf("wanted \n%s, \nbut got \n%v \n", "the decoder to accept: "+strconv.FormatBool(wanted)+" for "+payload, err)
+			}
+			if schemaAccepts(&schema, value) != wanted {
+				t.Errorf("wanted \n%s, \nbut got \n%v \n", "the schema to accept: "+strconv.FormatBool(wanted)+" for "+payload, !wanted)
+			}
+		}
+	}
+	check(valid, true)
+	check(invalid, false)
+}
+
+func TestVersions(t *testing.T) {
+	// the same request, as v1 and as v2, generates the same objects
+	v1 := `{"projectname": "boogie-test", "environment": "dev", "allowIngress": ["same-namespace"],
+		"egress": [{"dnsName": "registry.example.com"}],
+		"optionals": [
+			{"name": "cpu", "quantity": "2", "request": {"quantity": "500m"}},
+			{"name": "memory", "count": 4, "unit": "Gi"},
+			{"name": "pods", "count": 20}
+		]}`
+	v2 := `{"apiVersion": "provisioner/v2", "kind": "ProjectRequest",
+		"metadata": {"name": "boogie-test"},
+		"spec": {"environment": "dev",
+			"resources": [
+				{"name": "cpu", "limit": "2", "request": "500m"},
+				{"name": "memory", "limit": "4Gi"},
+				{"name": "pods", "limit": 20}
+			],
+			"network": {"allowIngress": ["same-namespace"], "egress": [{"dnsName": "registry.example.com"}]}
+		}}`
+	results := make([][]byte, 2)
+	for i, payload := range []string{v1, v2} {
+		d, err := DecodeRequest([]byte(payload))
+		if err != nil {
+			t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+		}
+		r, err := Generate(context.Background(), d)
+		if err != nil {
+			t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+		}
+		results[i], _ = json.Marshal(r)
+	}
+	if string(results[0]) != string(results[1]) {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", results[0], results[1])
+	}
+
+	// labels are only part of v2, and end up on the Project
+	d, err := DecodeRequest([]byte(`{"apiVersion": "provisioner/v2", "kind": "ProjectRequest",
+		"metadata": {"name": "boogie-test", "labels": {"team": "payments"}}, "spec": {"environment": "dev"}}`))
+	if err != nil {
+		t.Fatalf("wanted \n%s, \nbut got \n%s \n", "no error", err.Error())
+	}
+	_, project := createProjectObject(&d)
+	expectedBytes := `{"kind":"Project","apiVersion":"project.openshift.io/v1","metadata":{"name":"boogie-test","labels":{"team":"payments"}}}`
+	if gotBytes, _ := json.Marshal(project); string(gotBytes) != expectedBytes {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", expectedBytes, gotBytes)
+	}
+
+	// problems are reported with the paths of the version that was sent
+	_, err = DecodeRequest([]byte(`{"apiVersion": "provisioner/v2", "kind": "ProjectRequest",
+		"metadata": {"name": "boogie_test", "labels": {"team": "a b"}},
+		"spec": {"environment": "dev", "network": {"allowIngress": ["everything"]},
+			"resources": [{"name": "memory", "limit": "1Gi", "request": "2Gi"}, {"name": "memory"}, {"name": "cpu", "limit": "2", "count": 1}]
+		}}`))
+	wanted := []string{
+		"spec.resources[1].limit required",
+		"spec.resources[2].count unknown-field",
+		"spec.resources[1].name unique",
+		"metadata.name no-underscores",
+		"spec.network.allowIngress[0] enum",
+		"metadata.labels.team label-value",
+		"spec.resources[0].request max",
+	}
+	var got []string
+	if errs, ok := err.(ValidationErrors); ok {
+		for _, err := range errs {
+			got = append(got, err.Path+" "+err.Rule)
+		}
+	}
+	if strings.Join(got, "\n") != strings.Join(wanted, "\n") {
+		t.Errorf("wanted \n%s, \nbut got \n%s \n", strings.Join(wanted, "\n"), err)
+	}
+	_, err = DecodeRequest([]byte(`{"apiVersion": "provisioner/v2", "kind": "ProjectRequest", "metadata": {"name": "boogie-test"},
+		"spec": {"environment": "dev", "resources": [{"name": "pods", "limit": "300"}]}}`))
+	if policyErr, ok := err.(*PolicyError); !ok || policyErr.Violations[0].Path != "spec.resources[0]" {
+		t.Errorf("wanted \n%s, \nbut got \n%#v \n", "a violation at spec.resources[0]", err)
+	}
+
+	// and only known versions are accepted
+	_, err = DecodeRequest([]byte(`{"apiVersion": "provisioner/v3", "kind": "ProjectRequest"}`))
+	message := "apiVersion provisioner/v3 is not one of: provisioner/v1, provisioner/v2"
+	if err == nil || err.Error() != message {
+		t.Errorf("wanted \n%s, \nbut got \n%v \n", message, err)
+	}
+}
@@ -0,0 +1,165 @@
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+)
+
+/*
+	Batch mode: a single input may describe many projects, either as an array of requests:
+
+		[
+			{"projectname": "team-a", "environment": "dev"},
+			{"projectname": "team-a", "environment": "test"}
+		]
+
+	or as json lines (one request object per line), or as a yaml sequence of requests. Each request is validated
+	and processed independently, so one bad entry does not stop the rest from being generated.
+*/
+
+// BatchResult holds the outcome of a single request within a batch. Err is set when the request failed, in
+// which case Results is empty.
+type BatchResult struct {
+	Index       int               `json:"-"` // position of the request in the input, starting at 1
+	ProjectName string            `json:"projectname"`
+	Environment string            `json:"environment"`
+	Results     Results           `json:"results"`
+	Err         error             `json:"-"`
+	FromSize    []string          `json:"-"` // see Request.FromSize
+	Violations  []PolicyViolation `json:"-"` // see Request.Violations
+}
+
+// SplitRequests splits the input into individual requests, and reports whether it was a batch. A single json
+// object, or a yaml mapping, is not a batch.
+func SplitRequests(data []byte) ([]json.RawMessage, bool, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, false, &ValidationError{Rule: "required", Msg: "missing data"}
+	}
+	if trimmed[0] != '{' && trimmed[0] != '[' {
+		converted, err := yamlToJSON(trimmed)
+		if err != nil {
+			return nil, false, err
+		}
+		trimmed = converted
+	}
+
+	if trimmed[0] == '[' {
+		var requests []json.RawMessage
+		if err := json.Unmarshal(trimmed, &requests); err != nil {
+			return nil, false, err
+		}
+		if len(requests) == 0 {
+			return nil, false, &ValidationError{Rule: "min-items", Msg: "batch contains no requests"}
+		}
+		return requests, true, nil
+	}
+
+	// json lines, or simply one object
+	var requests []json.RawMessage
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	for {
+		var request json.RawMessage
+		err := decoder.Decode(&request)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false, errors.New("line " + strconv.Itoa(len(requests)+1) + ": " + err.Error())
+		}
+		requests = append(requests, request)
+	}
+	return requests, len(requests) > 1, nil
+}
+
+// GenerateBatch decodes and generates every request in turn under the default configuration, see
+// Provisioner.GenerateBatch.
+func GenerateBatch(ctx context.Context, requests []json.RawMessage) []BatchResult {
+	return defaultProvisioner().GenerateBatch(ctx, requests)
+}
+
+// GenerateBatch decodes and generates every request in turn. A failing request does not stop the others.
+func (p *Provisioner) GenerateBatch(ctx context.Context, requests []json.RawMessage) []BatchResult {
+	batch := make([]BatchResult, len(requests))
+	seen := make(map[string]int)
+
+	for i, request := range requests {
+		batch[i].Index = i + 1
+
+		var inputData Request
+		if err := ctx.Err(); err != nil {
+			batch[i].Err = err
+			continue
+		}
+		if err := inputData.decode(request, p.config); err != nil {
+			batch[i].Err = err
+			continue
+		}
+		batch[i].ProjectName = inputData.ProjectName
+		batch[i].Environment = inputData.Environment
+
+		// every project gets its own output set, so a project may only appear once per environment
+		key := inputData.Environment + "/" + inputData.ProjectName
+		if first, ok := seen[key]; ok {
+			batch[i].Err = &ValidationError{Field: "projectname", Path: "projectname", Value: inputData.ProjectName, Rule: "unique", Msg: "duplicate projectname for environment " + inputData.Environment + ", already requested by entry " + strconv.Itoa(first)}
+			continue
+		}
+		seen[key] = batch[i].Index
+
+		batch[i].FromSize = inputData.FromSize()
+		batch[i].Violations = inputData.Violations()
+		batch[i].Results, batch[i].Err = p.Generate(ctx, inputData)
+	}
+	return batch
+}
+
+// BatchErrorDocument describes every failed request of a batch as json, in the same terms as ErrorDocument:
+//
+//	{"requests": [{"index": 2, "projectname": "team-b", "environment": "dev", "errors": [...]}]}
+func BatchErrorDocument(batch []BatchResult) []byte {
+	type failure struct {
+		Index       int              `json:"index"`
+		ProjectName string           `json:"projectname,omitempty"`
+		Environment string           `json:"environment,omitempty"`
+		Errors      ValidationErrors `json:"errors"`
+	}
+	document := struct {
+		Requests []failure `json:"requests"`
+	}{Requests: []failure{}}
+	for _, result := range batch {
+		if result.Err != nil {
+			document.Requests = append(document.Requests, failure{result.Index, result.ProjectName, result.Environment, errorList(result.Err)})
+		}
+	}
+	b, _ := json.MarshalIndent(document, "", "  ")
+	return b
+}
+
+// MarshalBatch renders the successful results of a batch. json and yaml produce a list of per project results,
+// while stream and list combine the objects of every successful project into one document.
+func MarshalBatch(batch []BatchResult, format string) ([]byte, error) {
+	var succeeded []BatchResult
+	var combined Results
+	for _, result := range batch {
+		if result.Err != nil {
+			continue
+		}
+		succeeded = append(succeeded, result)
+		combined = append(combined, result.Results...)
+	}
+
+	switch format {
+	case FormatStream, FormatList:
+		// ordering across projects still puts every Project ahead of the objects that live inside them
+		return Marshal(combined, format)
+	default:
+		if succeeded == nil {
+			succeeded = []BatchResult{}
+		}
+		return MarshalObject(succeeded, format)
+	}
+}
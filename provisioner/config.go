@@ -0,0 +1,589 @@
+package provisioner
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+/*
+	Site specific configuration, loaded from a json or yaml file. It declares which environments requests may
+	target, along with defaults for each of them:
+
+		{
+			"environments": {
+				"dev": {
+					"optionals": [
+						{"name": "cpu", "count": 2},
+						{"name": "memory", "count": 4, "unit": "Gi"}
+					]
+				},
+				"test": {},
+				"prod": {}
+			}
+		}
+
+	Optionals listed for an environment are added to every request for that environment which does not ask for
+	them itself.
+
+	Every optional is subject to a ceiling per environment, given as a quantity and applying to both the limit
+	and the request. Ceilings that are not configured default to defaultCeilings. Requests that go over a
+	ceiling are rejected, unless they carry an override naming the approver, or the environment's enforcement is
+	"flag", in which case they are generated with the violations recorded on the Project (see policy.go):
+
+			"prod": {
+				"ceilings": {"cpu": "64", "memory": "256Gi", "pods": 1000, "services.loadbalancers": 0},
+				"enforcement": "reject"
+			}
+
+	Every project gets a LimitRange giving its containers default requests and limits when its quota limits cpu or
+	memory. It is derived from the quota, unless the environment configures one:
+
+			"dev": {
+				"limitRange": {
+					"default": {"cpu": "500m", "memory": "512Mi"},
+					"defaultRequest": {"cpu": "100m", "memory": "256Mi"},
+					"max": {"cpu": "2", "memory": "4Gi"},
+					"min": {"cpu": "10m", "memory": "16Mi"}
+				}
+			}
+
+	It also declares how AD group names are built, and which OpenShift roles get a group:
+
+		"groups": {
+			"template": "RES-{{upper .Environment}}-OPSH-{{upper .Role}}-{{upper (underscore .Project)}}",
+			"roles": [
+				{"clusterRole": "edit", "name": "DEVELOPER"},
+				{"clusterRole": "view", "name": "VIEWER"}
+			]
+		}
+
+	The template is a go text/template, with .Environment, .Project, .Role (the role's name) and .ClusterRole
+	available, and the functions upper, lower and underscore (replaces '-' with '_'). When left out, the above is
+	used.
+
+	Finally, it holds the catalogue of deployer identities: service accounts used by automation to deploy into
+	projects. Requests pick the ones they need by name, or get defaultDeployers when they don't say:
+
+		"deployers": {
+			"relman": {"serviceAccount": "relman", "namespace": "relman", "clusterRole": "admin", "filename": "10-jenkins-rolebinding.yaml"},
+			"tekton": {"serviceAccount": "pipeline", "namespace": "tekton-ci", "clusterRole": "edit"}
+		},
+		"defaultDeployers": ["relman"]
+
+	When deployers are left out, only relman is configured, and used by default.
+
+	Storage and volumes optionals may be limited per StorageClass, for the classes that are listed:
+
+		"storageClasses": ["fast-ssd", "standard", "backup"]
+
+	Without the list, only the project wide storage and volumes limits can be requested.
+
+	Requests may ask for a t-shirt size rather than spelling out every optional. Each size lists the optionals it
+	stands for, and may override the LimitRange. Optionals in the request replace those of the size, which in
+	turn replace the environment's:
+
+		"sizes": {
+			"small": {"optionals": [{"name": "cpu", "quantity": "1"}, {"name": "memory", "quantity": "2Gi"}]},
+			"large": {
+				"optionals": [{"name": "cpu", "quantity": "4"}, {"name": "memory", "quantity": "8Gi"}],
+				"limitRange": {"max": {"cpu": "2", "memory": "4Gi"}}
+			}
+		}
+
+	When sizes are left out, small, medium and large are configured, see defaultSizes.
+
+	Each Provisioner holds its own configuration, which is used while its requests are decoded and generated. The
+	package level functions, and json.Unmarshal into a Request, use the default configuration instead: whatever
+	was last given to SetConfig, or DefaultConfig when nothing was.
+*/
+
+// Config holds the settings that differ between sites, see LoadConfig.
+type Config struct {
+	Environments     map[string]EnvironmentConfig `json:"environments"`
+	Groups           GroupConfig                  `json:"groups"`
+	Deployers        map[string]DeployerConfig    `json:"deployers"`
+	DefaultDeployers []string                     `json:"defaultDeployers"`
+	StorageClasses   []string                     `json:"storageClasses,omitempty"`
+	Sizes            map[string]SizeConfig        `json:"sizes"`
+	Lenient          bool                         `json:"lenient,omitempty"` // decode requests the way older versions did, see strict.go
+}
+
+// EnvironmentConfig holds the settings for a single environment.
+type EnvironmentConfig struct {
+	Optionals   []Optional           `json:"optionals,omitempty"`
+	Ceilings    map[string]oQuantity `json:"ceilings,omitempty"`
+	Enforcement string               `json:"enforcement,omitempty"` // reject (the default) or flag
+	LimitRange  *LimitRangeConfig    `json:"limitRange,omitempty"`
+}
+
+// SizeConfig is a t-shirt size profile: the optionals, and optionally the LimitRange, that a request for the size
+// gets, unless it asks for them itself.
+type SizeConfig struct {
+	Optionals  []Optional        `json:"optionals"`
+	LimitRange *LimitRangeConfig `json:"limitRange,omitempty"`
+}
+
+// LimitRangeConfig holds the per container defaults and bounds for an environment, as quantities keyed by
+// resource (cpu or memory), eg. {"cpu": "500m", "memory": "512Mi"}.
+type LimitRangeConfig struct {
+	Default        map[string]string `json:"default,omitempty"`
+	DefaultRequest map[string]string `json:"defaultRequest,omitempty"`
+	Max            map[string]string `json:"max,omitempty"`
+	Min            map[string]string `json:"min,omitempty"`
+}
+
+// GroupConfig describes the AD groups that are bound to roles within every project.
+type GroupConfig struct {
+	Template string      `json:"template"`
+	Roles    []GroupRole `json:"roles"`
+
+	parsed *template.Template
+}
+
+// GroupRole binds the AD group for a role to an OpenShift ClusterRole. Name is the role as it appears in the
+// group name, eg. "DEVELOPER" for the "edit" ClusterRole. The ClusterRole names the binding and its file, so it
+// must be a DNS-1123 label.
+type GroupRole struct {
+	ClusterRole string `json:"clusterRole"`
+	Name        string `json:"name"`
+}
+
+// DeployerConfig is a service account that is given a ClusterRole within the project, so that it can deploy to
+// it. Filename defaults to "10-<deployer>-deployer-rolebinding.yaml", and must be a plain file name that no other
+// file of the project uses.
+type DeployerConfig struct {
+	ServiceAccount string `json:"serviceAccount"`
+	Namespace      string `json:"namespace"`
+	ClusterRole    string `json:"clusterRole"`
+	Filename       string `json:"filename,omitempty"`
+}
+
+const defaultGroupTemplate = "RES-{{upper .Environment}}-OPSH-{{upper .Role}}-{{upper (underscore .Project)}}"
+
+var groupTemplateFuncs = template.FuncMap{
+	"upper":      strings.ToUpper,
+	"lower":      strings.ToLower,
+	"underscore": func(s string) string { return strings.Replace(s, "-", "_", -1) },
+}
+
+var defaultCeilings = map[string]string{
+	"cpu":                    "32",
+	"memory":                 "128Gi",
+	"volumes":                "50",
+	"storage":                "2Ti",
+	"pods":                   "200",
+	"services":               "50",
+	"services.loadbalancers": "2",
+	"services.nodeports":     "5",
+	"secrets":                "200",
+	"configmaps":             "200",
+	"routes":                 "50",
+}
+
+func defaultSizes() map[string]SizeConfig {
+	size := func(cpu string, memory string, volumes string, storage string) SizeConfig {
+		return SizeConfig{Optionals: []Optional{
+			Optional{Name: oName{"cpu"}, Quantity: oQuantity{cpu}},
+			Optional{Name: oName{"memory"}, Quantity: oQuantity{memory}},
+			Optional{Name: oName{"volumes"}, Quantity: oQuantity{volumes}},
+			Optional{Name: oName{"storage"}, Quantity: oQuantity{storage}},
+		}}
+	}
+	return map[string]SizeConfig{
+		"small":  size("1", "2Gi", "2", "10Gi"),
+		"medium": size("2", "4Gi", "5", "50Gi"),
+		"large":  size("4", "8Gi", "10", "100Gi"),
+	}
+}
+
+var (
+	// guards defaultConfig, which is shared by all the package level functions
+	configLock    sync.RWMutex
+	defaultConfig = DefaultConfig()
+)
+
+func currentConfig() *Config {
+	configLock.RLock()
+	defer configLock.RUnlock()
+	return defaultConfig
+}
+
+// DefaultConfig returns the configuration used when none has been loaded.
+func DefaultConfig() *Config {
+	config := &Config{
+		Environments: map[string]EnvironmentConfig{
+			"dev":  EnvironmentConfig{},
+			"test": EnvironmentConfig{},
+			"acc":  EnvironmentConfig{},
+			"prod": EnvironmentConfig{},
+		},
+	}
+	config.validate()
+	return config
+}
+
+// LoadConfig reads and validates a configuration file, in json or yaml.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] != '{' {
+		if trimmed, err = yamlToJSON(trimmed); err != nil {
+			return nil, err
+		}
+	}
+	config := &Config{}
+	if err := json.Unmarshal(trimmed, config); err != nil {
+		return nil, errors.New("invalid config " + path + ": " + err.Error())
+	}
+	if err := config.validate(); err != nil {
+		return nil, errors.New("invalid config " + path + ": " + err.Error())
+	}
+	return config, nil
+}
+
+// SetConfig makes config the default configuration, used by the package level functions for all requests
+// decoded or generated afterwards. Use a Provisioner to work with more than one configuration.
+func SetConfig(config *Config) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	configLock.Lock()
+	defaultConfig = config
+	configLock.Unlock()
+	return nil
+}
+
+func (config *Config) validate() error {
+	if len(config.Environments) == 0 {
+		return errors.New("no environments configured")
+	}
+	// environment names are matched against lower cased requests
+	normalized := make(map[string]EnvironmentConfig)
+	if err := config.validateStorageClasses(); err != nil {
+		return err
+	}
+	for name, env := range config.Environments {
+		if name == "" || strings.ContainsAny(name, " _") {
+			return errors.New("environment name is invalid: " + name)
+		}
+		if err := checkOptionals(env.Optionals); err != nil {
+			return errors.New("environment " + name + ": " + err.Error())
+		}
+		for i, optional := range env.Optionals {
+			optional.StorageClass = strings.ToLower(optional.StorageClass)
+			env.Optionals[i] = optional
+			if err := checkStorageClass(optional, config.StorageClasses); err != nil {
+				return errors.New("environment " + name + ": " + err.Error())
+			}
+		}
+		if err := env.validatePolicy(); err != nil {
+			return errors.New("environment " + name + ": " + err.Error())
+		}
+		if env.LimitRange != nil {
+			if err := env.LimitRange.validate(); err != nil {
+				return errors.New("environment " + name + ": " + err.Error())
+			}
+		}
+		if violations := checkCeilings(env.Optionals, env.Ceilings); len(violations) > 0 {
+			return errors.New("environment " + name + ": " + (&PolicyError{Environment: name, Violations: violations}).Error())
+		}
+		// names are not case sensitive, so "Prod" and "prod" are the same environment
+		if _, ok := normalized[strings.ToLower(name)]; ok {
+			return errors.New("environment " + strings.ToLower(name) + " is defined more than once")
+		}
+		normalized[strings.ToLower(name)] = env
+	}
+	config.Environments = normalized
+	if err := config.validateSizes(); err != nil {
+		return err
+	}
+	if err := config.Groups.validate(); err != nil {
+		return err
+	}
+	return config.validateDeployers()
+}
+
+func (config *Config) validateDeployers() error {
+	if config.Deployers == nil {
+		config.Deployers = map[string]DeployerConfig{
+			"relman": DeployerConfig{ServiceAccount: "relman", Namespace: "relman", ClusterRole: "admin", Filename: jenkinsRolebindinngFilename},
+		}
+		if config.DefaultDeployers == nil {
+			config.DefaultDeployers = []string{"relman"}
+		}
+	}
+
+	// every file of a project is written to the same directory, so none may share a name
+	generated := generatedFilenames(config.Groups)
+	filenames := make(map[string]string)
+	for _, name := range config.deployerNames() {
+		deployer := config.Deployers[name]
+		if name == "" || name != strings.ToLower(name) || strings.ContainsAny(name, " _") {
+			return errors.New("deployer name is invalid: " + name)
+		}
+		if deployer.ServiceAccount == "" || deployer.Namespace == "" || deployer.ClusterRole == "" {
+			return errors.New("deployer " + name + " needs a serviceAccount, namespace and clusterRole")
+		}
+		if !validClusterRole(deployer.ClusterRole) {
+			return errors.New("deployer " + name + " has an invalid clusterRole, it must be a DNS-1123 label: " + deployer.ClusterRole)
+		}
+		if deployer.Filename == "" {
+			deployer.Filename = "10-" + name + "-deployer-rolebinding.yaml"
+			config.Deployers[name] = deployer
+		}
+		if deployer.Filename == "." || deployer.Filename == ".." || strings.ContainsAny(deployer.Filename, "/\\") {
+			return errors.New("deployer " + name + " filename must be a plain file name: " + deployer.Filename)
+		}
+		if other, ok := generated[deployer.Filename]; ok {
+			return errors.New("deployer " + name + " uses the filename of " + other + ": " + deployer.Filename)
+		}
+		if other, ok := filenames[deployer.Filename]; ok {
+			return errors.New("deployers " + other + " and " + name + " use the same filename: " + deployer.Filename)
+		}
+		filenames[deployer.Filename] = name
+	}
+	for _, name := range config.DefaultDeployers {
+		if _, ok := config.Deployers[name]; !ok {
+			return errors.New("default deployer is not configured: " + name)
+		}
+	}
+	return nil
+}
+
+func (config *Config) validateSizes() error {
+	if config.Sizes == nil {
+		config.Sizes = defaultSizes()
+	}
+	normalized := make(map[string]SizeConfig)
+	for name, size := range config.Sizes {
+		if name == "" || strings.ContainsAny(name, " _") {
+			return errors.New("size name is invalid: " + name)
+		}
+		if err := checkOptionals(size.Optionals); err != nil {
+			return errors.New("size " + name + ": " + err.Error())
+		}
+		for i, optional := range size.Optionals {
+			optional.StorageClass = strings.ToLower(optional.StorageClass)
+			size.Optionals[i] = optional
+			if err := checkStorageClass(optional, config.StorageClasses); err != nil {
+				return errors.New("size " + name + ": " + err.Error())
+			}
+		}
+		if size.LimitRange != nil {
+			if err := size.LimitRange.validate(); err != nil {
+				return errors.New("size " + name + ": " + err.Error())
+			}
+		}
+		// names are not case sensitive, so "Prod" and "prod" are the same size
+		if _, ok := normalized[strings.ToLower(name)]; ok {
+			return errors.New("size " + strings.ToLower(name) + " is defined more than once")
+		}
+		normalized[strings.ToLower(name)] = size
+	}
+	config.Sizes = normalized
+	return nil
+}
+
+func (config *Config) sizeNames() []string {
+	var names []string
+	for name := range config.Sizes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (limits *LimitRangeConfig) validate() error {
+	/*
+		every quantity has to parse, and for each resource: min <= defaultRequest <= default <= max, for those that
+		are set
+	*/
+	ordered := []map[string]string{limits.Min, limits.DefaultRequest, limits.Default, limits.Max}
+	names := []string{"min", "defaultRequest", "default", "max"}
+	for _, resource := range []string{"cpu", "memory"} {
+		var previous *quantity
+		var previousName string
+		for i, quantities := range ordered {
+			quantity, ok := quantities[resource]
+			if !ok {
+				continue
+			}
+			value, err := parseResourceQuantity(resource, quantity)
+			if err != nil {
+				return errors.New("limitRange " + names[i] + ": " + err.Error())
+			}
+			if previous != nil && value.cmp(*previous) < 0 {
+				return errors.New("limitRange " + names[i] + " " + resource + " must not be less than " + previousName)
+			}
+			previous, previousName = &value, names[i]
+		}
+	}
+	for i, quantities := range ordered {
+		for resource := range quantities {
+			if resource != "cpu" && resource != "memory" {
+				return errors.New("limitRange " + names[i] + " is not one of: cpu, memory, found: " + resource)
+			}
+		}
+	}
+	return nil
+}
+
+func (config *Config) validateStorageClasses() error {
+	seen := make(map[string]bool)
+	for i, class := range config.StorageClasses {
+		class = strings.ToLower(class)
+		if !validDNSName(class) {
+			return errors.New("storage class name is invalid: " + class)
+		}
+		if seen[class] {
+			return errors.New("storage class is listed more than once: " + class)
+		}
+		seen[class] = true
+		config.StorageClasses[i] = class
+	}
+	return nil
+}
+
+func (config *Config) deployerNames() []string {
+	var names []string
+	for name := range config.Deployers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (groups *GroupConfig) validate() error {
+	if groups.Template == "" {
+		groups.Template = defaultGroupTemplate
+	}
+	if groups.Roles == nil {
+		groups.Roles = []GroupRole{
+			GroupRole{ClusterRole: "edit", Name: "DEVELOPER"},
+			GroupRole{ClusterRole: "view", Name: "VIEWER"},
+		}
+	}
+
+	parsed, err := template.New("group").Funcs(groupTemplateFuncs).Option("missingkey=error").Parse(groups.Template)
+	if err != nil {
+		return errors.New("invalid group template: " + err.Error())
+	}
+	groups.parsed = parsed
+
+	seen := make(map[string]bool)
+	for _, role := range groups.Roles {
+		if !validClusterRole(role.ClusterRole) {
+			return errors.New("group role has an invalid clusterRole, it must be a DNS-1123 label: " + role.ClusterRole)
+		}
+		if seen[role.ClusterRole] {
+			return errors.New("group role is listed more than once: " + role.ClusterRole)
+		}
+		seen[role.ClusterRole] = true
+		// test run the template, so that generating never has to deal with a broken one
+		if _, err := groups.groupName("dev", "project-name", role); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var clusterRoleName = regexp.MustCompile(labelPattern)
+
+func validClusterRole(role string) bool {
+	// roles are part of the names and filenames of their bindings, so eg. "system:image-puller" can't be used
+	return len(role) <= maxLabelLength && clusterRoleName.MatchString(role)
+}
+
+func (groups *GroupConfig) groupName(environment string, project string, role GroupRole) (string, error) {
+	data := struct {
+		Environment string
+		Project     string
+		Role        string
+		ClusterRole string
+	}{environment, project, role.Name, role.ClusterRole}
+
+	var b bytes.Buffer
+	if err := groups.parsed.Execute(&b, data); err != nil {
+		return "", errors.New("invalid group template: " + err.Error())
+	}
+	if b.Len() == 0 {
+		return "", errors.New("group template produced an empty name for role " + role.ClusterRole)
+	}
+	return b.String(), nil
+}
+
+func (config *Config) environmentNames() []string {
+	var names []string
+	for name := range config.Environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func applyEnvironmentDefaults(input *Request, config *Config) error {
+	/*
+		rejects environments that are not configured, and adds the configured default optionals to the request,
+		unless it already specifies them.
+	*/
+	env, ok := config.Environments[input.Environment]
+	if !ok {
+		return &ValidationError{Field: "environment", Path: "environment", Value: input.Environment, Rule: "enum", Msg: "environment " + input.Environment + " is not one of: " + strings.Join(config.environmentNames(), ", ")}
+	}
+	// never modify the caller's slice
+	optionals := append([]Optional(nil), input.Optionals...)
+	for i := range optionals {
+		optionals[i].StorageClass = strings.ToLower(optionals[i].StorageClass)
+	}
+	input.Optionals = optionals
+	if err := applySize(input, config); err != nil {
+		return err
+	}
+	optionals = input.Optionals
+	for _, optional := range env.Optionals {
+		if input.getClassOptional(optional.Name.string, optional.StorageClass) == nil {
+			optionals = append(optionals, optional)
+		}
+	}
+	input.Optionals = optionals
+	return nil
+}
+
+func applySize(input *Request, config *Config) error {
+	/*
+		adds the optionals of the requested size, unless the request asks for them itself, and remembers which
+		ones it added so they can be reported. Requests are validated again when they are generated, by which time
+		the size's optionals are part of the request, so it is only applied once.
+	*/
+	if input.Size == "" || input.sizeApplied {
+		return nil
+	}
+	input.Size = strings.ToLower(input.Size)
+	size, ok := config.Sizes[input.Size]
+	if !ok {
+		return &ValidationError{Field: "size", Path: "size", Value: input.Size, Rule: "enum", Msg: "size " + input.Size + " is not one of: " + strings.Join(config.sizeNames(), ", ")}
+	}
+	var fromSize []string
+	for _, optional := range size.Optionals {
+		if input.getClassOptional(optional.Name.string, optional.StorageClass) == nil {
+			input.Optionals = append(input.Optionals, optional)
+			fromSize = append(fromSize, optional.describe())
+		}
+	}
+	if size.LimitRange != nil {
+		fromSize = append(fromSize, "limitRange")
+	}
+	input.fromSize = fromSize
+	input.sizeApplied = true
+	return nil
+}
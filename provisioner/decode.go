@@ -0,0 +1,151 @@
+package provisioner
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/*
+	Values of the wrong json type, eg. a count of "two", stop encoding/json at the first one, and its error says
+	nothing of where that value was. When that happens the request is decoded again, one value at a time, so that
+	every such value is reported with its path, and everything else is still decoded and validated as usual.
+*/
+
+func decodeTypes(data []byte, v interface{}) (ValidationErrors, error) {
+	err := json.Unmarshal(data, v)
+	if _, ok := err.(*json.UnmarshalTypeError); !ok {
+		// nil, or not json at all
+		return nil, err
+	}
+	value := reflect.ValueOf(v).Elem()
+	value.Set(reflect.Zero(value.Type()))
+	var problems ValidationErrors
+	decodeValue(data, value, "", &problems)
+	return problems, nil
+}
+
+func decodeValue(raw json.RawMessage, v reflect.Value, path string, problems *ValidationErrors) {
+	if string(raw) == "null" {
+		return
+	}
+	if _, leaf := schemaLeaves[v.Type()]; leaf {
+		if json.Unmarshal(raw, v.Addr().Interface()) != nil {
+			problems.add(typeError(raw, v.Type(), path))
+		}
+		return
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		v.Set(reflect.New(v.Type().Elem()))
+		decodeValue(raw, v.Elem(), path, problems)
+	case reflect.Slice:
+		var items []json.RawMessage
+		if json.Unmarshal(raw, &items) != nil {
+			problems.add(typeError(raw, v.Type(), path))
+			return
+		}
+		v.Set(reflect.MakeSlice(v.Type(), len(items), len(items)))
+		for i, item := range items {
+			decodeValue(item, v.Index(i), path+"["+strconv.Itoa(i)+"]", problems)
+		}
+	case reflect.Map:
+		var items map[string]json.RawMessage
+		if json.Unmarshal(raw, &items) != nil {
+			problems.add(typeError(raw, v.Type(), path))
+			return
+		}
+		v.Set(reflect.MakeMap(v.Type()))
+		for key, item := range items {
+			value := reflect.New(v.Type().Elem()).Elem()
+			decodeValue(item, value, fieldPath(path, key), problems)
+			v.SetMapIndex(reflect.ValueOf(key), value)
+		}
+	case reflect.Struct:
+		var object map[string]json.RawMessage
+		if json.Unmarshal(raw, &object) != nil {
+			problems.add(typeError(raw, v.Type(), path))
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if field.PkgPath != "" || name == "-" || name == "" {
+				continue
+			}
+			if item, ok := findKey(object, name); ok {
+				decodeValue(item, v.Field(i), fieldPath(path, name), problems)
+			}
+		}
+	default:
+		if json.Unmarshal(raw, v.Addr().Interface()) != nil {
+			problems.add(typeError(raw, v.Type(), path))
+		}
+	}
+}
+
+func findKey(object map[string]json.RawMessage, name string) (json.RawMessage, bool) {
+	// the same as encoding/json: an exact match, or failing that one in any case
+	if item, ok := object[name]; ok {
+		return item, true
+	}
+	for key, item := range object {
+		if strings.EqualFold(key, name) {
+			return item, true
+		}
+	}
+	return nil, false
+}
+
+func fieldPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+var pathIndex = regexp.MustCompile(`\[[0-9]+\]`)
+
+func typeError(raw json.RawMessage, t reflect.Type, path string) *ValidationError {
+	// objects and lists are left out of the value, there is no telling how big they are
+	var value string
+	if json.Unmarshal(raw, &value) != nil && raw[0] != '{' && raw[0] != '[' {
+		value = string(raw)
+	}
+	name := path
+	if name == "" {
+		name = "request"
+	}
+	found := string(raw)
+	switch raw[0] {
+	case '{':
+		found = "an object"
+	case '[':
+		found = "a list"
+	}
+	return &ValidationError{Field: pathIndex.ReplaceAllString(path, ""), Path: path, Value: value, Rule: "type", Msg: name + " must be " + typeName(t) + ", found: " + found}
+}
+
+func typeName(t reflect.Type) string {
+	switch t {
+	case reflect.TypeOf(oName{}), reflect.TypeOf(oUnit{}):
+		return "a string"
+	case reflect.TypeOf(oCount{}):
+		return "an integer"
+	case reflect.TypeOf(oQuantity{}):
+		return "a quantity, eg. \"1.5Gi\" or 0.5"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Int:
+		return "an integer"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Slice:
+		return "a list"
+	}
+	return "an object"
+}
@@ -0,0 +1,156 @@
+package provisioner
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+/*
+	Typed errors returned by the package, so that callers can tell a bad request apart from a problem on our side
+	without having to match on error strings.
+*/
+
+// ValidationError is returned when a request fails validation. Field names the offending part of the request
+// (eg. "projectname", or "optionals.unit"), and Path the exact spot within it (eg. "optionals[1].unit"). Value
+// holds what was supplied, where there is one, and Rule names the check that failed (eg. "enum").
+type ValidationError struct {
+	Field string `json:"-"`
+	Path  string `json:"path,omitempty"`
+	Value string `json:"value,omitempty"`
+	Rule  string `json:"rule,omitempty"`
+	Msg   string `json:"message"`
+}
+
+func (e *ValidationError) Error() string {
+	return e.Msg
+}
+
+// ValidationErrors is returned when a request has more than one problem, so that they can all be fixed at once.
+// A request with a single problem gets a *ValidationError instead.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	var messages []string
+	for _, err := range e {
+		messages = append(messages, err.Msg)
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *ValidationErrors) add(err error) {
+	switch err := err.(type) {
+	case nil:
+	case *ValidationError:
+		*e = append(*e, err)
+	case ValidationErrors:
+		*e = append(*e, err...)
+	default:
+		*e = append(*e, &ValidationError{Msg: err.Error()})
+	}
+}
+
+// at prefixes the paths of every error with path, eg. "optionals[1]"
+func (e ValidationErrors) at(path string) ValidationErrors {
+	for _, err := range e {
+		if err.Path == "" {
+			err.Path = path
+		} else {
+			err.Path = path + "." + err.Path
+		}
+	}
+	return e
+}
+
+// has reports whether any of the errors is at path
+func (e ValidationErrors) has(path string) bool {
+	for _, err := range e {
+		if err.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+func (e ValidationErrors) err() error {
+	switch len(e) {
+	case 0:
+		return nil
+	case 1:
+		return e[0]
+	}
+	return e
+}
+
+// PolicyError is returned when a request goes over the ceilings of its environment, without an override.
+type PolicyError struct {
+	Environment string
+	Violations  []PolicyViolation
+}
+
+func (e *PolicyError) Error() string {
+	var violations []string
+	for _, violation := range e.Violations {
+		violations = append(violations, violation.String())
+	}
+	return "request exceeds the ceilings for environment " + e.Environment + ": " + strings.Join(violations, "; ")
+}
+
+// withPaths returns a copy of e, with the path of every violation given by path
+func (e *PolicyError) withPaths(path func(string) string) *PolicyError {
+	violations := make([]PolicyViolation, len(e.Violations))
+	for i, violation := range e.Violations {
+		violation.Path = path(violation.Path)
+		violations[i] = violation
+	}
+	return &PolicyError{Environment: e.Environment, Violations: violations}
+}
+
+// FormatError is returned when results are requested in an output format that is not supported.
+type FormatError struct {
+	Format string
+}
+
+func (e *FormatError) Error() string {
+	return "unsupported output format: " + e.Format
+}
+
+// InternalError is returned when the generator itself produced something it cannot handle. It always
+// indicates a bug, rather than a problem with the request.
+type InternalError struct {
+	Msg string
+}
+
+func (e *InternalError) Error() string {
+	return e.Msg
+}
+
+// ErrorDocument describes err as json, for tools that display it to whoever made the request:
+//
+//	{"errors": [{"path": "optionals[1].unit", "value": "Gb", "rule": "enum", "message": "optional unit entry is invalid: Gb"}]}
+//
+// Policy violations are listed with the rule "ceiling", and any other error as a single entry holding its
+// message.
+func ErrorDocument(err error) []byte {
+	var document struct {
+		Errors ValidationErrors `json:"errors"`
+	}
+	document.Errors = errorList(err)
+	b, _ := json.MarshalIndent(document, "", "  ")
+	return b
+}
+
+func errorList(err error) ValidationErrors {
+	switch err := err.(type) {
+	case ValidationErrors:
+		return err
+	case *ValidationError:
+		return ValidationErrors{err}
+	case *PolicyError:
+		var errs ValidationErrors
+		for _, violation := range err.Violations {
+			errs = append(errs, &ValidationError{Path: violation.Path, Value: violation.Requested, Rule: "ceiling", Msg: violation.String()})
+		}
+		return errs
+	}
+	return ValidationErrors{&ValidationError{Msg: err.Error()}}
+}
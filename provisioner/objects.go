@@ -0,0 +1,792 @@
+package provisioner
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+	This package takes a basic input (what will eventually come from Helpline request / elsewhere) in json, and uses this, along with some basic
+    logic (based on standards) to produce the following files:
+    1. New project json
+	2. roleBinding json for EDIT Active Directory group to this new project*
+	3. roleBinding json for VIEW Active Directory group to this new project*
+	4. roleBinding json for each deployer service account (by default relman) to this new project
+	5. resource limit json, along with a LimitRange giving containers default requests and limits
+	6. networkPolicy for the project, along with any standard allow policies for ingress that were requested
+	7. egress networkPolicy for the project, allowing any requested destinations and denying everything else
+
+    *The AD group names are generated using the logic used to create the groups within active directory.
+*/
+
+const (
+	quotaFilename               string = "10-quotas.yaml"
+	projectFilename             string = "1-project.yaml"
+	defaultRolebindingFilename  string = "10-default-rolebinding.yaml"
+	jenkinsRolebindinngFilename string = "10-jenkins-rolebinding.yaml"
+	editRolebindingFilename     string = "10-edit-group-rolebinding.yaml"
+	viewRolebindingFilename     string = "10-view-group-rolebinding.yaml"
+	networkPolicyFilename       string = "10-networkpolicy.yaml"
+	egressNetworkPolicyFilename string = "10-egress-networkpolicy.yaml"
+	limitRangeFilename          string = "10-limitrange.yaml"
+)
+
+/*
+	Standard ingress policies that open up the deny-by-default policy, selectable per request.
+*/
+
+const (
+	allowSameNamespace  string = "same-namespace"
+	allowFromIngress    string = "openshift-ingress"
+	allowFromMonitoring string = "openshift-monitoring"
+)
+
+// in the order they are generated
+var ingressPolicies = []string{allowSameNamespace, allowFromIngress, allowFromMonitoring}
+
+/*
+	Composable minimal types used to create new json files.
+*/
+
+type metaData struct {
+	Name        string            `json:"name"`                  // binding name
+	NameSpace   string            `json:"namespace,omitempty"`   // projectname
+	Labels      map[string]string `json:"labels,omitempty"`      // only on the Project, see version.go
+	Annotations map[string]string `json:"annotations,omitempty"` // only on the Project, see policy.go
+}
+
+type roleRef struct {
+	Kind     string `json:"kind"`     // Project
+	APIGroup string `json:"apiGroup"` // rbac.authorization.k8s.io
+	Name     string `json:"name"`     // group name
+
+}
+
+type specQuota struct {
+	Hard hardQuota `json:"hard,omitempty"`
+}
+
+type hardQuota struct {
+	CPU            interface{} `json:"limits.cpu,omitempty"`
+	Memory         string      `json:"limits.memory,omitempty"`
+	RequestsCPU    interface{} `json:"requests.cpu,omitempty"`
+	RequestsMemory string      `json:"requests.memory,omitempty"`
+	PVC            int         `json:"persistentvolumeclaims,omitempty"`
+	Storage        string      `json:"requests.storage,omitempty"`
+
+	// object counts are pointers, as a cap of zero is just as valid as any other, eg. no load balancers at all
+	Pods          *int `json:"pods,omitempty"`
+	Services      *int `json:"services,omitempty"`
+	LoadBalancers *int `json:"services.loadbalancers,omitempty"`
+	NodePorts     *int `json:"services.nodeports,omitempty"`
+	Secrets       *int `json:"secrets,omitempty"`
+	ConfigMaps    *int `json:"configmaps,omitempty"`
+	Routes        *int `json:"count/routes.route.openshift.io,omitempty"`
+
+	StorageClasses map[string]storageClassQuota `json:"-"` // keyed by class, see MarshalJSON
+}
+
+type storageClassQuota struct {
+	PVC     int
+	Storage string
+}
+
+func (hard hardQuota) MarshalJSON() ([]byte, error) {
+	/*
+		the keys for storage classes are made up from the class names, so they can't be struct tags. They are
+		added after the fixed keys, sorted by class:
+
+			<class>.storageclass.storage.k8s.io/requests.storage
+			<class>.storageclass.storage.k8s.io/persistentvolumeclaims
+	*/
+	type fixed hardQuota
+	b, err := json.Marshal(fixed(hard))
+	if err != nil || len(hard.StorageClasses) == 0 {
+		return b, err
+	}
+	var classes []string
+	for class := range hard.StorageClasses {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+
+	buf := bytes.NewBuffer(b[:len(b)-1])
+	for _, class := range classes {
+		prefix := class + ".storageclass.storage.k8s.io/"
+		q := hard.StorageClasses[class]
+		if q.Storage != "" {
+			addQuotaKey(buf, prefix+"requests.storage", q.Storage)
+		}
+		if q.PVC != 0 {
+			addQuotaKey(buf, prefix+"persistentvolumeclaims", q.PVC)
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func addQuotaKey(buf *bytes.Buffer, key string, value interface{}) {
+	if buf.Len() > 1 {
+		buf.WriteByte(',')
+	}
+	k, _ := json.Marshal(key)
+	v, _ := json.Marshal(value)
+	buf.Write(k)
+	buf.WriteByte(':')
+	buf.Write(v)
+}
+
+type specNetwork struct {
+	PodSelector labelSelector `json:"podSelector"` // required - the empty selector selects every pod in the namespace
+	Ingress     []ingressRule `json:"ingress,omitempty"`
+	PolicyTypes []string      `json:"policyTypes,omitempty"`
+}
+
+type ingressRule struct {
+	From []networkPolicyPeer `json:"from,omitempty"`
+}
+
+type networkPolicyPeer struct {
+	// pointers, as an empty selector (everything) means something different from no selector at all
+	PodSelector       *labelSelector `json:"podSelector,omitempty"`
+	NamespaceSelector *labelSelector `json:"namespaceSelector,omitempty"`
+}
+
+type labelSelector struct {
+	MatchLabels      map[string]string          `json:"matchLabels,omitempty"`
+	MatchExpressions []labelSelectorRequirement `json:"matchExpressions,omitempty"`
+}
+
+type labelSelectorRequirement struct {
+	Key      string   `json:"key"`
+	Operator string   `json:"operator"` // In, NotIn, Exists or DoesNotExist
+	Values   []string `json:"values,omitempty"`
+}
+
+type specEgressNetwork struct {
+	Egress []egressRules `json:"egress"`
+}
+
+type egressRules struct {
+	EgressType string            `json:"type"`
+	To         EgressDestination `json:"to"`
+}
+
+type subject struct {
+	Kind      string `json:"kind"`                // Project
+	APIGroup  string `json:"apiGroup,omitempty"`  // rbac.authorization.k8s.io
+	Name      string `json:"name"`                // group name
+	Namespace string `json:"namespace,omitempty"` // name of project
+
+}
+
+type subjects []subject
+
+type baseObject struct {
+	Kind       string   `json:"kind"`       // Project
+	APIVersion string   `json:"apiVersion"` // project.openshift.io/v1
+	Metadata   metaData `json:"metadata"`
+}
+
+type roleBinding struct {
+	Kind       string   `json:"kind"`       // RoleBinding
+	APIVersion string   `json:"apiVersion"` // rbac.authorization.k8s.io/v1
+	Metadata   metaData `json:"metadata"`
+	Subjects   subjects `json:"subjects"`
+	RoleRef    roleRef  `json:"roleRef"`
+}
+
+type quota struct {
+	Kind       string    `json:"kind"`       // RoleBinding
+	APIVersion string    `json:"apiVersion"` // rbac.authorization.k8s.io/v1
+	Metadata   metaData  `json:"metadata"`
+	Spec       specQuota `json:"spec"`
+}
+
+type limitRange struct {
+	Kind       string         `json:"kind"`       // LimitRange
+	APIVersion string         `json:"apiVersion"` // v1
+	Metadata   metaData       `json:"metadata"`
+	Spec       specLimitRange `json:"spec"`
+}
+
+type specLimitRange struct {
+	Limits []limitRangeItem `json:"limits"`
+}
+
+// limitRangeItem holds quantities keyed by resource: cpu and memory
+type limitRangeItem struct {
+	Type           string            `json:"type"` // Container
+	Default        map[string]string `json:"default,omitempty"`
+	DefaultRequest map[string]string `json:"defaultRequest,omitempty"`
+	Max            map[string]string `json:"max,omitempty"`
+	Min            map[string]string `json:"min,omitempty"`
+}
+
+type network struct {
+	Kind       string      `json:"kind"`       // NetworkPolicy
+	APIVersion string      `json:"apiVersion"` // networking.k8s.io/v1
+	Metadata   metaData    `json:"metadata"`
+	Spec       specNetwork `json:"spec"`
+}
+
+type egressNetwork struct {
+	Kind       string            `json:"kind"`       // EgressNetworkPolicy
+	APIVersion string            `json:"apiVersion"` // network.openshift.io/v1
+	Metadata   metaData          `json:"metadata"`
+	Spec       specEgressNetwork `json:"spec"`
+}
+
+/*
+	Results: a simple nested json, where each key is the name of the destinationn file,
+	and the data, is the associated value
+*/
+
+type Entry struct {
+	Name    string      `json:"filename"`
+	Content interface{} `json:"content"`
+}
+
+type Results []Entry
+
+/*
+	Main functions for creating our serialized json objects
+*/
+
+func createProjectObject(data *Request) (string, baseObject) {
+	// create our object
+	y := baseObject{
+		Kind:       "Project",
+		APIVersion: "project.openshift.io/v1",
+	}
+	y.Metadata.Name = data.ProjectName
+	if len(data.Labels) > 0 {
+		// never share the caller's map
+		y.Metadata.Labels = make(map[string]string)
+		for key, value := range data.Labels {
+			y.Metadata.Labels[key] = value
+		}
+	}
+	y.Metadata.Annotations = policyAnnotations(data)
+
+	name := projectFilename
+	return name, y
+}
+
+func createNetworkPolicyObject(data *Request) (string, network) {
+
+	// create our NetworkPolicy object
+	y := network{
+		Kind:       "NetworkPolicy",
+		APIVersion: "networking.k8s.io/v1",
+	}
+	y.Metadata.Name = "deny-by-default"
+	y.Metadata.NameSpace = data.ProjectName
+	// an empty podSelector applies the policy to every pod, and with no rules listed nothing is allowed
+	y.Spec.PodSelector = labelSelector{}
+	y.Spec.PolicyTypes = []string{
+		"Ingress",
+		"Egress",
+	}
+
+	name := networkPolicyFilename
+
+	return name, y
+
+}
+
+func createIngressNetworkPolicyObjects(data *Request) ([]string, []network) {
+	/*
+		produces an allow policy for each of the standard sources of traffic requested, and none for requests
+		that don't mention them, which keeps the output of existing requests as it was:
+
+		1. same-namespace: pods within the project may talk to each other
+		2. openshift-ingress: the routers may reach pods, so that routes work
+		3. openshift-monitoring: the cluster monitoring stack may scrape pods
+	*/
+	var names []string
+	var policies []network
+
+	for _, source := range data.AllowIngress {
+		peer := networkPolicyPeer{}
+		switch source {
+		case allowSameNamespace:
+			peer.PodSelector = &labelSelector{}
+		case allowFromIngress:
+			peer.NamespaceSelector = &labelSelector{MatchLabels: map[string]string{"network.openshift.io/policy-group": "ingress"}}
+		case allowFromMonitoring:
+			peer.NamespaceSelector = &labelSelector{MatchLabels: map[string]string{"network.openshift.io/policy-group": "monitoring"}}
+		}
+
+		// create our NetworkPolicy object
+		y := network{
+			Kind:       "NetworkPolicy",
+			APIVersion: "networking.k8s.io/v1",
+		}
+		y.Metadata.Name = ingressPolicyName(source)
+		y.Metadata.NameSpace = data.ProjectName
+		y.Spec.PodSelector = labelSelector{}
+		y.Spec.Ingress = []ingressRule{ingressRule{From: []networkPolicyPeer{peer}}}
+		y.Spec.PolicyTypes = []string{"Ingress"}
+
+		names = append(names, ingressPolicyFilename(source))
+		policies = append(policies, y)
+	}
+	return names, policies
+}
+
+func ingressPolicyName(source string) string {
+	if source == allowSameNamespace {
+		return "allow-same-namespace"
+	}
+	return "allow-from-" + source
+}
+
+func ingressPolicyFilename(source string) string {
+	return "10-networkpolicy-" + ingressPolicyName(source) + ".yaml"
+}
+
+func createEgressNetworkPolicyObject(data *Request) (string, egressNetwork) {
+
+	// create our EgressNetworkPolicy object
+	e := egressNetwork{
+		Kind:       "EgressNetworkPolicy",
+		APIVersion: "network.openshift.io/v1",
+	}
+	e.Metadata.Name = "default-egress"
+	e.Metadata.NameSpace = data.ProjectName
+
+	// rules are evaluated in order, so everything that is allowed has to come before the final deny
+	for _, destination := range data.Egress {
+		e.Spec.Egress = append(e.Spec.Egress, egressRules{EgressType: "Allow", To: destination})
+	}
+	deny := egressRules{EgressType: "Deny"}
+	deny.To.Cidr = "0.0.0.0/0"
+	e.Spec.Egress = append(e.Spec.Egress, deny)
+
+	name := egressNetworkPolicyFilename
+
+	return name, e
+
+}
+
+func createRoleBindingObjects(data *Request, config *Config) ([]string, []roleBinding) {
+	/*
+		This function will produce the data for these files:
+
+		1. The generated AD groupname for each configured role (by default: EDIT and VIEW)
+		2. The service account of each requested deployer (by default relman, with the admin role)
+	*/
+	var names []string
+	var bytes []roleBinding
+
+	// first generate data for 1 above - in the configured order, ranging over the map would differ between runs
+	adRolesAndGroupNames := generateADGroupNames(data, config)
+	for _, role := range config.Groups.Roles {
+		roleName := strings.ToUpper(role.ClusterRole)
+		adGroupName := adRolesAndGroupNames[roleName]
+		roleBindingName := strings.ToLower(data.ProjectName + "-" + roleName + "-" + "binding")
+		// create our object
+		y := roleBinding{
+			Kind:       "RoleBinding",
+			APIVersion: "rbac.authorization.k8s.io/v1",
+		}
+		y.Metadata.Name = roleBindingName
+		y.Metadata.NameSpace = data.ProjectName
+		y.Subjects = subjects{
+			subject{
+				Kind:     "Group",
+				APIGroup: "rbac.authorization.k8s.io",
+				Name:     adGroupName,
+			},
+		}
+		y.RoleRef.APIGroup = "rbac.authorization.k8s.io"
+		y.RoleRef.Kind = "ClusterRole"
+		y.RoleRef.Name = strings.ToLower(roleName)
+
+		// eg. editRolebindingFilename
+		name := groupRolebindingFilename(role)
+
+		// add to results
+		names = append(names, name)
+		bytes = append(bytes, y)
+	}
+	// now do 2, for every deployer the request asked for
+	for _, deployerName := range data.deployers(config) {
+		deployer := config.Deployers[deployerName]
+		roleName := deployer.ClusterRole + "-" + deployerName
+		roleBindingName := strings.ToLower(data.ProjectName + "-" + roleName + "-" + "binding")
+		// create our object
+		y := roleBinding{
+			Kind:       "RoleBinding",
+			APIVersion: "rbac.authorization.k8s.io/v1",
+		}
+		y.Metadata.Name = roleBindingName
+		y.Metadata.NameSpace = data.ProjectName
+		y.Subjects = subjects{
+			subject{
+				Kind:      "ServiceAccount",
+				Name:      deployer.ServiceAccount,
+				Namespace: deployer.Namespace,
+			},
+		}
+		y.RoleRef.APIGroup = "rbac.authorization.k8s.io"
+		y.RoleRef.Kind = "ClusterRole"
+		y.RoleRef.Name = deployer.ClusterRole
+
+		// add to results
+		names = append(names, deployer.Filename)
+		bytes = append(bytes, y)
+	}
+
+	return names, bytes
+}
+
+func groupRolebindingFilename(role GroupRole) string {
+	return "10-" + strings.ToLower(role.ClusterRole) + "-group-rolebinding.yaml"
+}
+
+// generatedFilenames are the files that may be generated for a project other than those of deployers, along with
+// what each of them holds
+func generatedFilenames(groups GroupConfig) map[string]string {
+	names := map[string]string{
+		projectFilename:             "the project",
+		quotaFilename:               "the quota",
+		limitRangeFilename:          "the limit range",
+		networkPolicyFilename:       "the network policy",
+		egressNetworkPolicyFilename: "the egress network policy",
+	}
+	for _, source := range ingressPolicies {
+		names[ingressPolicyFilename(source)] = "the " + source + " network policy"
+	}
+	for _, role := range groups.Roles {
+		names[groupRolebindingFilename(role)] = "the " + role.ClusterRole + " group binding"
+	}
+	return names
+}
+
+func createLimitsObject(data *Request) (string, quota) {
+	if data.Optionals == nil {
+		// should never happen, but if so, handle it
+		return "", quota{}
+	}
+	// create our object
+	y := quota{
+		Kind:       "ResourceQuota",
+		APIVersion: "v1",
+	}
+	y.Metadata.Name = "default-quotas"
+	y.Metadata.NameSpace = data.ProjectName
+
+	// now get the optionals
+	if o := data.getOptional("cpu"); o != nil {
+		y.Spec.Hard.CPU = cpuQuantity(o.limitString())
+		if o.Request != nil {
+			y.Spec.Hard.RequestsCPU = cpuQuantity(o.requestString())
+		}
+	}
+
+	if o := data.getOptional("memory"); o != nil {
+		y.Spec.Hard.Memory = o.limitString()
+		if o.Request != nil {
+			y.Spec.Hard.RequestsMemory = o.requestString()
+		}
+	}
+
+	if o := data.getOptional("volumes"); o != nil {
+		y.Spec.Hard.PVC = o.count()
+	}
+
+	if o := data.getOptional("storage"); o != nil {
+		y.Spec.Hard.Storage = o.limitString()
+	}
+
+	for _, name := range objectCounts {
+		if o := data.getOptional(name); o != nil {
+			count := o.count()
+			*y.Spec.Hard.objectCount(name) = &count
+		}
+	}
+
+	for _, o := range data.Optionals {
+		if o.StorageClass == "" {
+			continue
+		}
+		if y.Spec.Hard.StorageClasses == nil {
+			y.Spec.Hard.StorageClasses = make(map[string]storageClassQuota)
+		}
+		q := y.Spec.Hard.StorageClasses[o.StorageClass]
+		if o.Name.string == "storage" {
+			q.Storage = o.limitString()
+		} else {
+			q.PVC = o.count()
+		}
+		y.Spec.Hard.StorageClasses[o.StorageClass] = q
+	}
+
+	name := quotaFilename
+
+	return name, y
+}
+
+func createLimitRangeObject(data *Request, config *Config) (string, limitRange) {
+	/*
+		once the quota limits cpu or memory, pods that don't say what they need are rejected. The LimitRange fills
+		in defaults for those containers.
+
+		A limitRange configured for the size, or else for the environment, is used as is. Otherwise, it is derived
+		from the quota, per resource: a container may use at most the whole quota, gets a quarter of it by
+		default, and requests half of that by default. Derived ranges have no minimum, and their defaults never
+		exceed the maximum, however small the quota.
+	*/
+	item := limitRangeItem{Type: "Container"}
+	configured := config.Environments[data.Environment].LimitRange
+	if size, ok := config.Sizes[data.Size]; ok && size.LimitRange != nil {
+		configured = size.LimitRange
+	}
+	if configured != nil {
+		item.Default = configured.Default
+		item.DefaultRequest = configured.DefaultRequest
+		item.Max = configured.Max
+		item.Min = configured.Min
+	} else {
+		for _, resource := range []string{"cpu", "memory"} {
+			o := data.getOptional(resource)
+			if o == nil {
+				continue
+			}
+			max, err := o.limit()
+			if err != nil {
+				continue
+			}
+			if item.Max == nil {
+				item.Default = make(map[string]string)
+				item.DefaultRequest = make(map[string]string)
+				item.Max = make(map[string]string)
+			}
+			item.Max[resource] = max.String()
+			item.Default[resource] = max.fraction(1, 4).floor(resource).atMost(max).String()
+			item.DefaultRequest[resource] = max.fraction(1, 8).floor(resource).atMost(max).String()
+		}
+	}
+	if item.Default == nil && item.DefaultRequest == nil && item.Max == nil && item.Min == nil {
+		return "", limitRange{}
+	}
+
+	l := limitRange{
+		Kind:       "LimitRange",
+		APIVersion: "v1",
+	}
+	l.Metadata.Name = "default-limits"
+	l.Metadata.NameSpace = data.ProjectName
+	l.Spec.Limits = []limitRangeItem{item}
+
+	return limitRangeFilename, l
+}
+
+/*
+Object count quotas, by optional name. Each caps the number of objects of a kind within the project, and
+is subject to the ceilings configured for the environment, like every other optional. A count of zero allows
+none at all.
+
+Routes may also be requested by their quota key, see optionalAliases.
+*/
+var objectCounts = []string{"pods", "services", "services.loadbalancers", "services.nodeports", "secrets", "configmaps", "routes"}
+
+// optionalAliases maps the other names that optionals may be requested by to their own
+var optionalAliases = map[string]string{"count/routes.route.openshift.io": "routes"}
+
+func isObjectCount(name string) bool {
+	return inList(name, objectCounts)
+}
+
+func (hard *hardQuota) objectCount(name string) **int {
+	switch name {
+	case "pods":
+		return &hard.Pods
+	case "services":
+		return &hard.Services
+	case "services.loadbalancers":
+		return &hard.LoadBalancers
+	case "services.nodeports":
+		return &hard.NodePorts
+	case "secrets":
+		return &hard.Secrets
+	case "configmaps":
+		return &hard.ConfigMaps
+	case "routes":
+		return &hard.Routes
+	}
+	return nil
+}
+
+func cpuQuantity(s string) interface{} {
+	// CPU can be specified with, and without a suffix - whole cores stay numbers
+	if count, err := strconv.Atoi(s); err == nil {
+		return count
+	}
+	return s
+}
+
+func (o Optional) limitString() string {
+	// the canonical form of the limit, see quantity.go. Optionals are validated before they get here.
+	q, err := o.limit()
+	if err != nil {
+		return o.Quantity.string + concat(o.Count.int, o.Unit.string)
+	}
+	return q.String()
+}
+
+func (o Optional) requestString() string {
+	q, err := o.request()
+	if err != nil {
+		return o.Request.Quantity.string + concat(o.Request.Count.int, o.Request.Unit.string)
+	}
+	return q.String()
+}
+
+func (o Optional) count() int {
+	// object counts, which may be given as a quantity too
+	if q, err := o.limit(); err == nil && q.isWhole() {
+		return q.intValue()
+	}
+	return o.Count.int
+}
+
+func concat(i int, s string) string {
+	return strconv.Itoa(i) + s
+}
+
+func process(data *Request, config *Config) (Results, error) {
+	/*
+		Populate our Results here with each file and its contents. The order of entries is part of the output
+		and is always the same for the same input:
+
+		1. the Project
+		2. role bindings: one per configured group role (EDIT, then VIEW by default), then one per deployer
+		3. the ResourceQuota (only when limits were requested), then the LimitRange (only when the quota limits
+		   cpu or memory, or the environment configures one)
+		4. the deny-by-default NetworkPolicy, followed by the requested allow policies: same-namespace,
+		   openshift-ingress, then openshift-monitoring
+		5. the EgressNetworkPolicy
+	*/
+
+	results := Results{}
+	if err := results.addPayload(createProjectObject(data)); err != nil {
+		return nil, err
+	}
+	if err := results.addPayload(createRoleBindingObjects(data, config)); err != nil {
+		return nil, err
+	}
+	if err := results.addPayload(createLimitsObject(data)); err != nil {
+		return nil, err
+	}
+	if err := results.addPayload(createLimitRangeObject(data, config)); err != nil {
+		return nil, err
+	}
+	if err := results.addPayload(createNetworkPolicyObject(data)); err != nil {
+		return nil, err
+	}
+	if err := results.addPayload(createIngressNetworkPolicyObjects(data)); err != nil {
+		return nil, err
+	}
+	if err := results.addPayload(createEgressNetworkPolicyObject(data)); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+/*
+	Helpers
+*/
+
+func (results *Results) addSliceType(name []string, d interface{}) bool {
+	switch data := d.(type) {
+	case []roleBinding:
+		// now work on each set of data in turn
+		for i := range name {
+			r := Entry{}
+			r.Name = name[i]
+			r.Content = data[i]
+			*results = append(*results, r)
+		}
+		return true
+	case []network:
+		for i := range name {
+			r := Entry{}
+			r.Name = name[i]
+			r.Content = data[i]
+			*results = append(*results, r)
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+func isEmptyObject(d interface{}) bool {
+	// quotas may be empty if no limits were supplied - if so, we want to avoid adding it
+	switch object := d.(type) {
+	case quota:
+		if reflect.DeepEqual(quota{}, object) {
+			return true
+		}
+	case limitRange:
+		if reflect.DeepEqual(limitRange{}, object) {
+			return true
+		}
+	}
+	return false
+}
+
+func (results *Results) addPayload(f interface{}, d interface{}) error {
+	/*
+		f can be a string, or []string - d can be an object, or a slice of them
+	*/
+	if isEmptyObject(d) {
+		return nil
+	}
+
+	switch name := f.(type) {
+	case string:
+		r := Entry{}
+		r.Name = name
+		r.Content = d
+		*results = append(*results, r)
+
+	case []string:
+		// assert that data is of the expected type, and add correct type
+		ok := results.addSliceType(name, d)
+		if !ok {
+			return &InternalError{Msg: "invalid datatype passed, expected []roleBinding or []network"}
+		}
+
+	default:
+		return &InternalError{Msg: "invalid datatype passed"}
+	}
+	return nil
+}
+
+func generateADGroupNames(data *Request, config *Config) map[string]string {
+	/*
+		AD groups names are generated from the configured template, by default:
+
+		"RES" + "-" + environment + "-" + "OPSH" + "-" + role + "-" + project_name
+
+		returns a map of "OPENSHIFT ROLE" : "AD GROUP NAME"
+
+		Templates are test run when the config is loaded, and again for every request during validation, so
+		failing to execute here is not expected.
+	*/
+	s := make(map[string]string)
+	for _, role := range config.Groups.Roles {
+		name, _ := config.Groups.groupName(data.Environment, data.ProjectName, role)
+		s[strings.ToUpper(role.ClusterRole)] = name
+	}
+	return s
+}
@@ -0,0 +1,163 @@
+package provisioner
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+/*
+	Quota policy: every environment has a ceiling for each optional, which neither the limit nor the request may
+	go over. What happens to requests that do depends on the environment's enforcement:
+
+	- reject (the default): the request fails with a PolicyError listing every violation, unless it carries an
+	  override naming the approver who agreed to it:
+
+		"override": {"approver": "jane.doe", "reason": "load test environment"}
+
+	- flag: the request is generated anyway
+
+	Either way, violations that are let through are recorded as annotations on the Project, along with the
+	approver when there is one, so that they can be found later.
+*/
+
+const (
+	enforcementReject = "reject"
+	enforcementFlag   = "flag"
+
+	annotationViolations = "provisioner/quota-violations"
+	annotationApprover   = "provisioner/quota-override-approver"
+	annotationReason     = "provisioner/quota-override-reason"
+)
+
+// Override records who approved a request going over the environment's ceilings, and why.
+type Override struct {
+	Approver string `json:"approver"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// PolicyViolation is a single value that goes over its ceiling. Key is the optional, followed by " request" for
+// the request of cpu or memory, and by the storage class in brackets for those that limit one. Path is where the
+// value sits in the request, eg. "optionals[0].request", counting the optionals added by its size and environment.
+type PolicyViolation struct {
+	Key       string `json:"key"`
+	Path      string `json:"path"`
+	Requested string `json:"requested"`
+	Allowed   string `json:"allowed"`
+}
+
+func (v PolicyViolation) String() string {
+	return v.Key + " requested " + v.Requested + ", allowed " + v.Allowed
+}
+
+func (env *EnvironmentConfig) validatePolicy() error {
+	switch env.Enforcement {
+	case "":
+		env.Enforcement = enforcementReject
+	case enforcementReject, enforcementFlag:
+	default:
+		return errors.New("enforcement is not one of: " + enforcementReject + ", " + enforcementFlag + ", found: " + env.Enforcement)
+	}
+
+	ceilings := make(map[string]oQuantity)
+	for key, ceiling := range defaultCeilings {
+		ceilings[key] = oQuantity{ceiling}
+	}
+	for key, ceiling := range env.Ceilings {
+		if alias, ok := optionalAliases[key]; ok {
+			key = alias
+		}
+		if !validName(key) {
+			return errors.New("ceiling is not for a valid optional name: " + key)
+		}
+		if _, err := parseResourceQuantity(key, ceiling.string); err != nil {
+			return errors.New("ceiling for " + key + ": " + err.Error())
+		}
+		ceilings[key] = ceiling
+	}
+	env.Ceilings = ceilings
+	return nil
+}
+
+func checkCeilings(optionals []Optional, ceilings map[string]oQuantity) []PolicyViolation {
+	// returns every limit or request that goes over its ceiling, in the order of the optionals
+	var violations []PolicyViolation
+	check := func(key string, path string, requested quantity, err error, ceiling quantity) {
+		if err == nil && requested.cmp(ceiling) > 0 {
+			violations = append(violations, PolicyViolation{Key: key, Path: path, Requested: requested.String(), Allowed: ceiling.String()})
+		}
+	}
+	for i, optional := range optionals {
+		ceiling, ok := ceilings[optional.Name.string]
+		if !ok {
+			continue
+		}
+		allowed, err := parseQuantity(ceiling.string)
+		if err != nil {
+			continue
+		}
+		key := optional.Name.string
+		if optional.StorageClass != "" {
+			key += " (" + optional.StorageClass + ")"
+		}
+		path := "optionals[" + strconv.Itoa(i) + "]"
+		limit, err := optional.limit()
+		check(key, path, limit, err, allowed)
+		if optional.Request != nil {
+			request, err := optional.request()
+			check(key+" request", path+".request", request, err, allowed)
+		}
+	}
+	return violations
+}
+
+func validateOverride(input *Request) error {
+	if input.Override == nil {
+		return nil
+	}
+	// never modify the caller's override
+	override := *input.Override
+	override.Approver = strings.TrimSpace(override.Approver)
+	input.Override = &override
+	if override.Approver == "" {
+		return &ValidationError{Field: "override.approver", Path: "override.approver", Rule: "required", Msg: "override needs an approver"}
+	}
+	return nil
+}
+
+func applyPolicy(input *Request, config *Config) error {
+	/*
+		called once the request is otherwise valid, and its optionals are complete, including those from its size
+		and environment
+	*/
+	env := config.Environments[input.Environment]
+	input.violations = checkCeilings(input.Optionals, env.Ceilings)
+	if len(input.violations) > 0 && input.Override == nil && env.Enforcement != enforcementFlag {
+		return &PolicyError{Environment: input.Environment, Violations: input.violations}
+	}
+	return nil
+}
+
+// Violations returns the ceilings that the request goes over, and that were let through by an override or by the
+// environment's enforcement. It is only set once the request has been validated.
+func (input Request) Violations() []PolicyViolation {
+	return input.violations
+}
+
+func policyAnnotations(input *Request) map[string]string {
+	if len(input.violations) == 0 {
+		return nil
+	}
+	var violations []string
+	for _, violation := range input.violations {
+		violations = append(violations, violation.String())
+	}
+	annotations := map[string]string{annotationViolations: strings.Join(violations, "; ")}
+	if input.Override != nil {
+		annotations[annotationApprover] = input.Override.Approver
+		if input.Override.Reason != "" {
+			annotations[annotationReason] = input.Override.Reason
+		}
+	}
+	return annotations
+}
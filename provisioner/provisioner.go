@@ -0,0 +1,51 @@
+/*
+Package provisioner generates the OpenShift objects needed to onboard a new project: the Project itself, role
+bindings for its Active Directory groups and deployment service account, resource quotas and network policies.
+
+Requests are usually decoded from json or yaml with DecodeRequest (or SplitRequests for batches), which validates
+them as they are decoded. Generate turns a request into Results: a list of files and the object each of them
+should contain, which Marshal can then render in any of the supported output formats.
+
+The package level functions all use the default configuration, see SetConfig. Programs that need more than one
+configuration, eg. a service that provisions for several sites, create a Provisioner for each instead.
+*/
+package provisioner
+
+import (
+	"context"
+)
+
+// Provisioner decodes and generates requests under a single configuration. It never modifies its configuration,
+// so it may be used from any number of goroutines at once.
+type Provisioner struct {
+	config *Config
+}
+
+// New validates config and returns a Provisioner that uses it.
+func New(config *Config) (*Provisioner, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return &Provisioner{config: config}, nil
+}
+
+func defaultProvisioner() *Provisioner {
+	return &Provisioner{config: currentConfig()}
+}
+
+// Generate validates a request under the default configuration and produces the complete set of objects for it.
+func Generate(ctx context.Context, req Request) (Results, error) {
+	return defaultProvisioner().Generate(ctx, req)
+}
+
+// Generate validates a request and produces the complete set of objects for it.
+func (p *Provisioner) Generate(ctx context.Context, req Request) (Results, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// requests built in code have not been through the decoders, so always validate
+	if err := req.validate(p.config); err != nil {
+		return nil, err
+	}
+	return process(&req, p.config)
+}
@@ -0,0 +1,241 @@
+package provisioner
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+)
+
+/*
+	Kubernetes resource quantities, eg. "1.5Gi", "500m", "2000Mi" or "1e3": a (possibly fractional) number,
+	followed by either
+
+	- a binary suffix: Ki, Mi, Gi, Ti, Pi, Ei
+	- a decimal suffix: n, u, m, k, M, G, T, P, E, or none at all
+	- an exponent: e or E followed by a whole number, eg. "1e3" for 1000
+
+	Quantities are written back out in canonical form: the largest suffix that keeps the number whole, using
+	binary suffixes for quantities that were given with one, and decimal suffixes otherwise. "1.5Gi" becomes
+	"1536Mi", "0.5" becomes "500m", and "1e3" becomes "1k".
+*/
+
+type quantity struct {
+	value  *big.Rat
+	binary bool
+	// the suffix or exponent the quantity was given with when it is below one, eg. "m" or "e-3"
+	fractional string
+}
+
+type suffix struct {
+	suffix     string
+	multiplier *big.Rat
+}
+
+func power(base int64, exponent int) *big.Rat {
+	r := big.NewRat(1, 1)
+	for i := 0; i < exponent; i++ {
+		r.Mul(r, big.NewRat(base, 1))
+	}
+	for i := 0; i > exponent; i-- {
+		r.Quo(r, big.NewRat(base, 1))
+	}
+	return r
+}
+
+// both from largest to smallest, so that formatting can take the first that fits
+var binarySuffixes = []suffix{
+	{"Ei", power(2, 60)}, {"Pi", power(2, 50)}, {"Ti", power(2, 40)}, {"Gi", power(2, 30)}, {"Mi", power(2, 20)}, {"Ki", power(2, 10)},
+}
+
+var decimalSuffixes = []suffix{
+	{"E", power(10, 18)}, {"P", power(10, 15)}, {"T", power(10, 12)}, {"G", power(10, 9)}, {"M", power(10, 6)}, {"k", power(10, 3)},
+	{"", power(10, 0)}, {"m", power(10, -3)}, {"u", power(10, -6)}, {"n", power(10, -9)},
+}
+
+// quantities are never formatted more precisely than this
+var milli = power(10, -3)
+
+// no quantity we deal with needs an exponent anywhere near this, and it keeps huge exponents from eating memory
+const maxExponent = 30
+
+func parseQuantity(s string) (quantity, error) {
+	invalid := errors.New("invalid quantity: " + s)
+
+	// the number is everything up to the first character that can't be part of it
+	end := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' && r != '+' && r != '-' })
+	if end < 0 {
+		end = len(s)
+	}
+	number, rest := s[:end], s[end:]
+	digits := strings.TrimLeft(number, "+-")
+	if len(number)-len(digits) > 1 || strings.Trim(digits, ".") == "" || strings.ContainsAny(digits, "+-") || strings.Count(digits, ".") > 1 {
+		return quantity{}, invalid
+	}
+	value, ok := new(big.Rat).SetString(number)
+	if !ok {
+		return quantity{}, invalid
+	}
+
+	q := quantity{value: value}
+	switch {
+	case rest == "":
+	case len(rest) > 1 && (rest[0] == 'e' || rest[0] == 'E') && rest != "Ei":
+		exponent := rest[1:]
+		unsigned := strings.TrimLeft(exponent, "+-")
+		if len(exponent)-len(unsigned) > 1 || unsigned == "" || strings.Trim(unsigned, "0123456789") != "" || len(unsigned) > 2 {
+			return quantity{}, invalid
+		}
+		e := 0
+		for _, r := range unsigned {
+			e = e*10 + int(r-'0')
+		}
+		if strings.HasPrefix(exponent, "-") {
+			e = -e
+			q.fractional = rest
+		}
+		if e > maxExponent || e < -maxExponent {
+			return quantity{}, invalid
+		}
+		q.value.Mul(q.value, power(10, e))
+	default:
+		multiplier := findSuffix(rest, binarySuffixes)
+		q.binary = multiplier != nil
+		if !q.binary {
+			multiplier = findSuffix(rest, decimalSuffixes)
+		}
+		if multiplier == nil {
+			return quantity{}, invalid
+		}
+		if multiplier.Cmp(big.NewRat(1, 1)) < 0 {
+			q.fractional = rest
+		}
+		q.value.Mul(q.value, multiplier)
+	}
+	return q, nil
+}
+
+func findSuffix(s string, suffixes []suffix) *big.Rat {
+	for _, sfx := range suffixes {
+		if sfx.suffix == s {
+			return sfx.multiplier
+		}
+	}
+	return nil
+}
+
+// legacyQuantity builds the quantity for a count and unit pair, where "K" has always been accepted for k
+func legacyQuantity(count int, unit string) (quantity, error) {
+	if unit == "K" {
+		unit = "k"
+	}
+	return parseQuantity(concat(count, unit))
+}
+
+func (q quantity) String() string {
+	if q.value.Sign() == 0 {
+		return "0"
+	}
+	if q.binary && q.value.IsInt() {
+		for _, sfx := range binarySuffixes {
+			if scaled := new(big.Rat).Quo(q.value, sfx.multiplier); scaled.IsInt() {
+				return scaled.Num().String() + sfx.suffix
+			}
+		}
+		return q.value.Num().String()
+	}
+	for _, sfx := range decimalSuffixes {
+		if scaled := new(big.Rat).Quo(q.value, sfx.multiplier); scaled.IsInt() {
+			return scaled.Num().String() + sfx.suffix
+		}
+	}
+	// checkQuantity rejects these, but never lose anything when formatting
+	return q.value.FloatString(9)
+}
+
+func (q quantity) cmp(other quantity) int {
+	return q.value.Cmp(other.value)
+}
+
+// isWhole is true for quantities without a fractional part, the only ones intValue can return
+func (q quantity) isWhole() bool {
+	return q.value.IsInt()
+}
+
+func (q quantity) intValue() int {
+	return int(q.value.Num().Int64())
+}
+
+func checkQuantity(resource string, q quantity) error {
+	/*
+		the same quantity means different things for different resources, so each has its own rules:
+
+		- cpu is counted in cores, down to 1m, and has no business with binary suffixes
+		- memory and storage are counted in bytes, which can't be split, so "m", "u" and "n" make no sense for them
+		  even when the result is whole, eg. "1000m"
+		- everything else is a number of objects
+	*/
+	if q.value.Sign() < 0 {
+		return errors.New(resource + " quantity must not be negative: " + q.String())
+	}
+	switch resource {
+	case "cpu":
+		if q.binary {
+			return errors.New("cpu quantity must not use a binary suffix: " + q.String())
+		}
+		if !new(big.Rat).Quo(q.value, milli).IsInt() {
+			return errors.New("cpu quantity must be a whole number of millicores: " + q.String())
+		}
+	case "memory", "storage":
+		if q.fractional != "" {
+			return errors.New(resource + " quantity must be in bytes, found suffix: " + q.fractional)
+		}
+		if !q.isWhole() {
+			return errors.New(resource + " quantity must be a whole number of bytes: " + q.String())
+		}
+	default:
+		if !q.isWhole() || q.value.Num().BitLen() > 31 {
+			return errors.New(resource + " quantity must be a whole number: " + q.String())
+		}
+	}
+	return nil
+}
+
+func parseResourceQuantity(resource string, s string) (quantity, error) {
+	q, err := parseQuantity(s)
+	if err != nil {
+		return q, err
+	}
+	return q, checkQuantity(resource, q)
+}
+
+func (q quantity) fraction(numerator int64, denominator int64) quantity {
+	return quantity{value: new(big.Rat).Mul(q.value, big.NewRat(numerator, denominator)), binary: q.binary}
+}
+
+// atMost returns the smaller of q and max
+func (q quantity) atMost(max quantity) quantity {
+	if q.cmp(max) > 0 {
+		return max
+	}
+	return q
+}
+
+func (q quantity) floor(resource string) quantity {
+	/*
+		rounds down to whole millicores for cpu, and to whole Mi (or Ki, for very small values) for memory, but
+		never to zero, so the result may be more than q. See atMost.
+	*/
+	step := milli
+	if resource == "memory" {
+		step = power(2, 20)
+		if q.value.Cmp(step) < 0 {
+			step = power(2, 10)
+		}
+	}
+	scaled := new(big.Rat).Quo(q.value, step)
+	count := new(big.Int).Quo(scaled.Num(), scaled.Denom())
+	if count.Sign() == 0 {
+		count.SetInt64(1)
+	}
+	return quantity{value: new(big.Rat).Mul(new(big.Rat).SetInt(count), step), binary: resource == "memory"}
+}
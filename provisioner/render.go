@@ -0,0 +1,96 @@
+package provisioner
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Output formats supported by Marshal.
+const (
+	FormatJSON   string = "json"
+	FormatYAML   string = "yaml"
+	FormatStream string = "stream" // multi-document yaml, for piping into "oc apply -f -"
+	FormatList   string = "list"   // a single json "kind: List" document, for piping into "oc apply -f -"
+)
+
+/*
+	kubectl style List wrapper, used when all objects are rendered as a single json document
+*/
+
+type objectList struct {
+	Kind       string        `json:"kind"`       // List
+	APIVersion string        `json:"apiVersion"` // v1
+	Items      []interface{} `json:"items"`
+}
+
+// MarshalObject serializes a single object, such as the Content of an Entry, as json or yaml.
+func MarshalObject(v interface{}, format string) ([]byte, error) {
+	switch format {
+	case FormatJSON:
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	case FormatYAML:
+		return marshalYAML(v)
+	default:
+		return nil, &FormatError{Format: format}
+	}
+}
+
+// Marshal renders a complete set of results. json and yaml produce the Results themselves, while stream and
+// list produce only the objects, ordered so that they can be applied in one go.
+func Marshal(results Results, format string) ([]byte, error) {
+	switch format {
+	case FormatStream:
+		var data []byte
+		for _, entry := range orderedResults(results) {
+			doc, err := marshalYAML(entry.Content)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, "---\n"...)
+			data = append(data, doc...)
+		}
+		return data, nil
+	case FormatList:
+		list := objectList{
+			Kind:       "List",
+			APIVersion: "v1",
+			Items:      []interface{}{},
+		}
+		for _, entry := range orderedResults(results) {
+			list.Items = append(list.Items, entry.Content)
+		}
+		return MarshalObject(list, FormatJSON)
+	default:
+		return MarshalObject(results, format)
+	}
+}
+
+func orderedResults(results Results) Results {
+	/*
+		files are named with a numeric prefix ("1-project.yaml", "10-quotas.yaml") which dictates the order they
+		must be applied in - the Project has to exist before anything namespaced is created within it. Entries
+		sharing a prefix keep their original order.
+	*/
+	ordered := make(Results, len(results))
+	copy(ordered, results)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return filenamePrefix(ordered[i].Name) < filenamePrefix(ordered[j].Name)
+	})
+	return ordered
+}
+
+func filenamePrefix(name string) int {
+	// files without a numeric prefix are applied last
+	digits := strings.SplitN(name, "-", 2)[0]
+	prefix, err := strconv.Atoi(digits)
+	if err != nil {
+		return int(^uint(0) >> 1)
+	}
+	return prefix
+}
@@ -0,0 +1,868 @@
+package provisioner
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+	Expected input accepted by this tool. This is effectively the API, and is published as a JSON Schema, see
+	schema.go.
+	By defininng our own custom decoders, we are able to apply any "checkin logic" at the time of decoding, as well as
+	extending it as needed without poluting the rest of the codebase (decoupling)
+
+
+		Example of expected input supplied at runtime via "prereqs.json" file:
+		{
+			"projectname": "nic-test-backbase-reference",
+			"environment": "dev",
+			"optionals":[
+						{
+							"name":"cpu",
+							"count": 1
+						},
+						{
+							"name":"memory",
+							"count":1,
+							"unit":"Gi"
+						},
+						{
+							"name":"volumes",
+							"count":2
+						},
+						{
+							"name":"storage",
+							"count":10,
+							"unit":"Gi"
+						}
+			]
+		}
+
+
+*/
+
+// Request describes a single project to be provisioned.
+type Request struct {
+	ProjectName  string              `json:"projectname"`
+	Environment  string              `json:"environment"`
+	Optionals    []Optional          `json:"optionals,omitempty"`
+	Deployers    []string            `json:"deployers,omitempty"`    // nil means the configured defaults, empty means none
+	AllowIngress []string            `json:"allowIngress,omitempty"` // the standard allow policies to generate, none unless asked for
+	Egress       []EgressDestination `json:"egress,omitempty"`       // destinations allowed ahead of the final deny
+	Size         string              `json:"size,omitempty"`         // t-shirt size profile, see Config
+	Override     *Override           `json:"override,omitempty"`     // approval for going over the ceilings, see policy.go
+	Labels       map[string]string   `json:"-"`                      // added to the Project, only in v2 requests, see version.go
+
+	fromSize    []string // what the size profile added, see FromSize
+	sizeApplied bool
+	violations  []PolicyViolation
+}
+
+// FromSize describes the values that were taken from the request's size profile rather than the request itself,
+// eg. "cpu: 2". It is only set once the request has been validated.
+func (input Request) FromSize() []string {
+	return input.fromSize
+}
+
+// Optional is a single resource limit within a Request. Use NewOptional to build one in code.
+type Optional struct {
+	Name     oName     `json:"name"`
+	Count    oCount    `json:"count"`
+	Unit     oUnit     `json:"unit,omitempty"`
+	Quantity oQuantity `json:"quantity,omitempty"` // instead of count and unit, eg. "1.5Gi" or "500m"
+	Request  *oRequest `json:"request,omitempty"`  // cpu and memory only: the requests.* quota, limits.* being the above
+
+	StorageClass string `json:"storageClass,omitempty"` // storage and volumes only: limits just the one StorageClass
+}
+
+// oRequest is the request side of an Optional. When unit is left out, the Optional's unit is used.
+type oRequest struct {
+	Count    oCount    `json:"count"`
+	Unit     oUnit     `json:"unit,omitempty"`
+	Quantity oQuantity `json:"quantity,omitempty"`
+}
+
+type optionalObjects []Optional
+
+// EgressDestination is a single destination that the project may reach, given as either a cidrSelector or a
+// dnsName, never both.
+type EgressDestination struct {
+	Cidr string `json:"cidrSelector,omitempty"`
+	URL  string `json:"dnsName,omitempty"`
+}
+
+type oName struct {
+	string
+}
+
+type oCount struct {
+	int
+}
+
+type oUnit struct {
+	string
+}
+
+type oQuantity struct {
+	string
+}
+
+func (o *oName) UnmarshalJSON(data []byte) error {
+	var c string
+	if err := json.Unmarshal(data, &c); err != nil {
+		return err
+	}
+	// right type, the value is checked by validate along with everything else
+	o.string = optionalName(c)
+	return nil
+}
+
+func (o *oCount) UnmarshalJSON(data []byte) error {
+	var c int
+	if err := json.Unmarshal(data, &c); err != nil {
+		return err
+	}
+	o.int = c
+	return nil
+}
+
+func (o *oUnit) UnmarshalJSON(data []byte) error {
+	var c string
+	if err := json.Unmarshal(data, &c); err != nil {
+		return err
+	}
+	// right type, the value is checked by validate along with everything else
+	o.string = c
+	return nil
+}
+
+func (o *oQuantity) UnmarshalJSON(data []byte) error {
+	// quantities are strings, but plain numbers are accepted too, eg. 0.5 for "500m"
+	var c string
+	if err := json.Unmarshal(data, &c); err != nil {
+		var n json.Number
+		if json.Unmarshal(data, &n) != nil {
+			return err
+		}
+		c = n.String()
+	}
+	// right type, the value is checked by validate along with everything else
+	o.string = c
+	return nil
+}
+
+func (o Optional) describe() string {
+	name := o.Name.string
+	if o.StorageClass != "" {
+		name += " (" + o.StorageClass + ")"
+	}
+	return name + ": " + o.limitString()
+}
+
+func (o Optional) limit() (quantity, error) {
+	if o.Quantity.string != "" {
+		return parseQuantity(o.Quantity.string)
+	}
+	return legacyQuantity(o.Count.int, o.Unit.string)
+}
+
+func (o Optional) request() (quantity, error) {
+	if o.Request.Quantity.string != "" {
+		return parseQuantity(o.Request.Quantity.string)
+	}
+	unit := o.Request.Unit.string
+	if unit == "" {
+		unit = o.Unit.string
+	}
+	return legacyQuantity(o.Request.Count.int, unit)
+}
+
+// WithRequest returns a copy of o that also sets the request for o's resource, next to its limit.
+func (o Optional) WithRequest(count int, unit string) (Optional, error) {
+	o.Request = &oRequest{Count: oCount{count}, Unit: oUnit{unit}}
+	return o, checkOptional(o).err()
+}
+
+// NewQuantityOptional builds a validated optional from a quantity, eg. "1.5Gi", rather than a count and unit.
+func NewQuantityOptional(name string, q string) (Optional, error) {
+	o := Optional{Name: oName{optionalName(name)}, Quantity: oQuantity{q}}
+	return o, checkOptional(o).err()
+}
+
+// WithStorageClass returns a copy of o that limits only storageClass, rather than all storage in the project. Which
+// storage classes there are depends on the configuration, so that is checked when the request is generated.
+func (o Optional) WithStorageClass(storageClass string) (Optional, error) {
+	o.StorageClass = strings.ToLower(storageClass)
+	return o, checkClassOptional(o)
+}
+
+func (input *Request) getOptional(name string) *Optional {
+	// simple helper that looks for, and then returns an Optional with a name that matches name
+	return input.getClassOptional(name, "")
+}
+
+func (input *Request) getClassOptional(name string, storageClass string) *Optional {
+	// as getOptional, for the optional that limits storageClass
+	for _, object := range input.Optionals {
+		if object.Name.string == name && object.StorageClass == storageClass {
+			return &object
+		}
+	}
+	return nil
+}
+
+func checkClassOptional(optional Optional) error {
+	// whatever the configuration, only storage can be limited per storage class
+	if optional.StorageClass != "" && optional.Name.string != "storage" && optional.Name.string != "volumes" {
+		return &ValidationError{Field: "optionals.storageClass", Path: "storageClass", Value: optional.StorageClass, Rule: "not-supported", Msg: "optional storageClass is only supported for: storage, volumes"}
+	}
+	return nil
+}
+
+func checkStorageClass(optional Optional, storageClasses []string) error {
+	if optional.StorageClass == "" {
+		return nil
+	}
+	if err := checkClassOptional(optional); err != nil {
+		return err
+	}
+	if len(storageClasses) == 0 {
+		return &ValidationError{Field: "optionals.storageClass", Path: "storageClass", Value: optional.StorageClass, Rule: "enum", Msg: "storageClass " + optional.StorageClass + " is not allowed, no storage classes are configured"}
+	}
+	if !inList(optional.StorageClass, storageClasses) {
+		return &ValidationError{Field: "optionals.storageClass", Path: "storageClass", Value: optional.StorageClass, Rule: "enum", Msg: "storageClass " + optional.StorageClass + " is not one of: " + strings.Join(storageClasses, ", ")}
+	}
+	return nil
+}
+
+func (input *Request) deployers(config *Config) []string {
+	// requests that don't mention deployers get the configured defaults
+	if input.Deployers == nil {
+		return config.DefaultDeployers
+	}
+	return input.Deployers
+}
+
+func validateDeployers(input *Request, config *Config) error {
+	if input.Deployers == nil {
+		return nil
+	}
+	var errs ValidationErrors
+	seen := make(map[string]bool)
+	deployers := []string{}
+	for i, name := range input.Deployers {
+		name = strings.ToLower(name)
+		path := "deployers[" + strconv.Itoa(i) + "]"
+		if _, ok := config.Deployers[name]; !ok {
+			errs.add(&ValidationError{Field: "deployers", Path: path, Value: name, Rule: "enum", Msg: "deployer " + name + " is not one of: " + strings.Join(config.deployerNames(), ", ")})
+			continue
+		}
+		if seen[name] {
+			errs.add(&ValidationError{Field: "deployers", Path: path, Value: name, Rule: "unique", Msg: "deployer is listed more than once: " + name})
+			continue
+		}
+		seen[name] = true
+		deployers = append(deployers, name)
+	}
+	if len(errs) == 0 {
+		input.Deployers = deployers
+	}
+	return errs.err()
+}
+
+func validateAllowIngress(input *Request) error {
+	if input.AllowIngress == nil {
+		return nil
+	}
+	var errs ValidationErrors
+	requested := make(map[string]bool)
+	for i, source := range input.AllowIngress {
+		source = strings.ToLower(source)
+		path := "allowIngress[" + strconv.Itoa(i) + "]"
+		if !inList(source, ingressPolicies) {
+			errs.add(&ValidationError{Field: "allowIngress", Path: path, Value: source, Rule: "enum", Msg: "allowIngress " + source + " is not one of: " + strings.Join(ingressPolicies, ", ")})
+			continue
+		}
+		if requested[source] {
+			errs.add(&ValidationError{Field: "allowIngress", Path: path, Value: source, Rule: "unique", Msg: "allowIngress is listed more than once: " + source})
+			continue
+		}
+		requested[source] = true
+	}
+	if len(errs) > 0 {
+		return errs.err()
+	}
+	// always generated in the same order, whatever order they were asked for in
+	allowed := []string{}
+	for _, source := range ingressPolicies {
+		if requested[source] {
+			allowed = append(allowed, source)
+		}
+	}
+	input.AllowIngress = allowed
+	return nil
+}
+
+func (e *EgressDestination) validate() error {
+	/*
+		exactly one of cidrSelector or dnsName has to be set. Both are normalized, so that duplicates can be spotted:
+		cidrs to their network address, and dns names to lower case.
+	*/
+	switch {
+	case e.Cidr != "" && e.URL != "":
+		return &ValidationError{Field: "egress", Value: e.Cidr + ", " + e.URL, Rule: "one-of", Msg: "egress entry must have either a cidrSelector or a dnsName, not both"}
+	case e.Cidr != "":
+		_, network, err := net.ParseCIDR(e.Cidr)
+		if err != nil || network.IP.To4() == nil {
+			return &ValidationError{Field: "egress.cidrSelector", Path: "cidrSelector", Value: e.Cidr, Rule: "cidr", Msg: "egress cidrSelector is not a valid IPv4 CIDR: " + e.Cidr}
+		}
+		e.Cidr = network.String()
+	case e.URL != "":
+		name := strings.ToLower(strings.TrimSuffix(e.URL, "."))
+		if !validDNSName(name) {
+			return &ValidationError{Field: "egress.dnsName", Path: "dnsName", Value: e.URL, Rule: "dns-name", Msg: "egress dnsName is not a valid DNS name: " + e.URL}
+		}
+		e.URL = name
+	default:
+		return &ValidationError{Field: "egress", Rule: "one-of", Msg: "egress entry must have either a cidrSelector or a dnsName"}
+	}
+	return nil
+}
+
+func (e EgressDestination) String() string {
+	if e.Cidr != "" {
+		return e.Cidr
+	}
+	return e.URL
+}
+
+const (
+	maxDNSNameLength = 253
+	// OpenShift rejects EgressNetworkPolicies with more rules than this, and the final deny is one of them
+	maxEgressRules = 50
+)
+
+func validDNSName(name string) bool {
+	// a DNS-1123 subdomain: dot separated labels. Wildcards are not supported by EgressNetworkPolicy
+	if name == "" || len(name) > maxDNSNameLength {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > maxLabelLength {
+			return false
+		}
+		for _, r := range label {
+			if !isLabelChar(r) && r != '-' {
+				return false
+			}
+		}
+		if !isLabelChar(rune(label[0])) || !isLabelChar(rune(label[len(label)-1])) {
+			return false
+		}
+	}
+	return true
+}
+
+func validateEgress(input *Request) error {
+	if len(input.Egress)+1 > maxEgressRules {
+		return &ValidationError{Field: "egress", Path: "egress", Value: strconv.Itoa(len(input.Egress)), Rule: "max-items", Msg: "egress allows at most " + strconv.Itoa(maxEgressRules-1) + " destinations, found: " + strconv.Itoa(len(input.Egress))}
+	}
+	var errs ValidationErrors
+	seen := make(map[string]bool)
+	// never modify the caller's slice
+	egress := make([]EgressDestination, len(input.Egress))
+	for i, destination := range input.Egress {
+		path := "egress[" + strconv.Itoa(i) + "]"
+		if err := destination.validate(); err != nil {
+			errs.add(ValidationErrors{err.(*ValidationError)}.at(path))
+			continue
+		}
+		if seen[destination.String()] {
+			errs.add(&ValidationError{Field: "egress", Path: path, Value: destination.String(), Rule: "unique", Msg: "egress destination is listed more than once: " + destination.String()})
+			continue
+		}
+		seen[destination.String()] = true
+		egress[i] = destination
+	}
+	if len(errs) > 0 {
+		return errs.err()
+	}
+	if input.Egress != nil {
+		input.Egress = egress
+	}
+	return nil
+}
+
+const (
+	// label keys are a name, optionally prefixed with a DNS subdomain and a slash
+	labelNamePattern  = `[A-Za-z0-9]([-A-Za-z0-9_.]{0,61}[A-Za-z0-9])?`
+	labelKeyPattern   = `^([a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*/)?` + labelNamePattern + `$`
+	labelValuePattern = `^(` + labelNamePattern + `)?$`
+)
+
+var (
+	labelKey   = regexp.MustCompile(labelKeyPattern)
+	labelValue = regexp.MustCompile(labelValuePattern)
+)
+
+func validateLabels(input *Request) error {
+	// the labels end up on the Project, so they have to be valid Kubernetes labels
+	var keys []string
+	for key := range input.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var errs ValidationErrors
+	for _, key := range keys {
+		path := "labels." + key
+		if prefix := strings.Split(key, "/")[0]; !labelKey.MatchString(key) || (strings.Contains(key, "/") && len(prefix) > maxDNSNameLength) {
+			errs.add(&ValidationError{Field: "labels", Path: path, Value: key, Rule: "label-key", Msg: "label key is invalid: " + key})
+			continue
+		}
+		if value := input.Labels[key]; !labelValue.MatchString(value) {
+			errs.add(&ValidationError{Field: "labels", Path: path, Value: value, Rule: "label-value", Msg: "label value for " + key + " is invalid: " + value})
+		}
+	}
+	return errs.err()
+}
+
+func inList(s string, list []string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+const maxLabelLength = 63
+
+func validateProjectName(input *Request, config *Config) error {
+	/*
+		the project name becomes the namespace, so it has to be a valid DNS-1123 label:
+
+		- at most 63 characters
+		- only lower case alphanumeric characters or '-'
+		- starts and ends with an alphanumeric character
+
+		names of the objects we generate inside the project are built from the project name, so those have to
+		stay within the same limit too.
+	*/
+	name := input.ProjectName
+	if len(name) > maxLabelLength {
+		return &ValidationError{Field: "projectname", Path: "projectname", Value: name, Rule: "max-length", Msg: "projectname must be no more than " + strconv.Itoa(maxLabelLength) + " characters"}
+	}
+	for _, r := range name {
+		if !isLabelChar(r) && r != '-' {
+			return &ValidationError{Field: "projectname", Path: "projectname", Value: name, Rule: "dns-label", Msg: "projectname must consist of lower case alphanumeric characters or '-', found: " + string(r)}
+		}
+	}
+	if !isLabelChar(rune(name[0])) || !isLabelChar(rune(name[len(name)-1])) {
+		return &ValidationError{Field: "projectname", Path: "projectname", Value: name, Rule: "dns-label", Msg: "projectname must start and end with an alphanumeric character"}
+	}
+
+	_, bindings := createRoleBindingObjects(input, config)
+	for _, binding := range bindings {
+		if len(binding.Metadata.Name) > maxLabelLength {
+			return &ValidationError{Field: "projectname", Path: "projectname", Value: name, Rule: "max-length", Msg: "projectname is too long, generated name " + binding.Metadata.Name + " must be no more than " + strconv.Itoa(maxLabelLength) + " characters"}
+		}
+	}
+	return nil
+}
+
+func isLabelChar(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9')
+}
+
+// resources limited by an optional, next to the objectCounts
+var resources = []string{"cpu", "memory", "volumes", "storage"}
+
+var units = []string{"Mi", "Gi", "Ti", "Ki", "K", "M", "G", "T", "m"}
+
+func optionalName(name string) string {
+	name = strings.ToLower(name)
+	if alias, ok := optionalAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+func optionalNames() []string {
+	return append(append([]string(nil), resources...), objectCounts...)
+}
+
+func validName(name string) bool {
+	/*
+	  returns true if objects are all contained in:
+	  "cpu","memory","volumes","storage", or one of the objectCounts
+	*/
+	inList := false
+
+	for _, valid := range optionalNames() {
+		if valid == name {
+			inList = true
+		}
+	}
+	return inList
+
+}
+
+func validUnit(unit string) bool {
+	/*
+	  returns true if objects are all contained in:
+	  "Mi", "Gi", "Ti", "Ki", "K", "M", "G", "T", "m"
+
+	  "Mi", "Gi", "Ti", "Ki", "K", "M", "G", "T" are valid units for Memory, and "m" is valid for CPU
+	*/
+	inList := false
+
+	for _, valid := range units {
+		if valid == unit {
+			inList = true
+		}
+	}
+	return inList
+
+}
+
+func validUnitDependency(optional Optional) bool {
+	/*
+
+		certain objects are invalid without both a count and a unit. Since count is compulsory, we use
+		the combination of "name" and "unit" to check that a unit has been specified.
+
+	*/
+	if optional.Name.string == "memory" || optional.Name.string == "storage" {
+		if optional.Unit.string == "" {
+			return false
+		}
+	}
+	return true
+}
+
+func checkOptionals(opts []Optional) error {
+	var errs ValidationErrors
+	for i, optional := range opts {
+		errs.add(checkOptional(optional).at("optionals[" + strconv.Itoa(i) + "]"))
+	}
+	return errs.err()
+}
+
+func checkOptional(optional Optional) ValidationErrors {
+	/*
+		every problem with a single optional, with paths relative to it. Checks that depend on earlier ones are
+		skipped when those fail, so that a single mistake is reported only once.
+	*/
+	var errs ValidationErrors
+	name := optional.Name.string
+	if !validName(name) {
+		errs.add(&ValidationError{Field: "optionals.name", Path: "name", Value: name, Rule: "enum", Msg: "optional name entry is invalid: " + name})
+		return errs
+	}
+	if optional.Unit.string != "" && !validUnit(optional.Unit.string) {
+		errs.add(&ValidationError{Field: "optionals.unit", Path: "unit", Value: optional.Unit.string, Rule: "enum", Msg: "optional unit entry is invalid: " + optional.Unit.string})
+	}
+	if optional.Request != nil && optional.Request.Unit.string != "" && !validUnit(optional.Request.Unit.string) {
+		errs.add(&ValidationError{Field: "optionals.request.unit", Path: "request.unit", Value: optional.Request.Unit.string, Rule: "enum", Msg: "optional unit entry is invalid: " + optional.Request.Unit.string})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	if optional.Quantity.string == "" && !validUnitDependency(optional) {
+		errs.add(&ValidationError{Field: "optionals.unit", Path: "unit", Value: optional.Unit.string, Rule: "unit-required", Msg: "invalid or missing unit for: " + name})
+		return errs
+	}
+	if isObjectCount(name) && optional.Unit.string != "" {
+		errs.add(&ValidationError{Field: "optionals.unit", Path: "unit", Value: optional.Unit.string, Rule: "unit-not-allowed", Msg: "unit is not allowed for: " + name})
+		return errs
+	}
+	if err := checkQuantities(optional); err != nil {
+		errs.add(err)
+		return errs
+	}
+	errs.add(checkRequest(optional))
+	return errs
+}
+
+func checkQuantities(optional Optional) error {
+	/*
+		an optional is either a count and unit, or a quantity. Whichever it is, it has to make sense for the
+		resource it limits, and so does its request.
+	*/
+	var errs ValidationErrors
+	name := optional.Name.string
+	if optional.Quantity.string != "" && (optional.Count.int != 0 || optional.Unit.string != "") {
+		errs.add(&ValidationError{Field: "optionals.quantity", Path: "quantity", Value: optional.Quantity.string, Rule: "one-of", Msg: "optional " + name + " takes either a count and unit, or a quantity, not both"})
+	} else if q, err := optional.limit(); err != nil {
+		errs.add(&ValidationError{Field: "optionals.quantity", Path: "quantity", Value: optional.Quantity.string, Rule: "quantity", Msg: err.Error()})
+	} else if err := checkQuantity(name, q); err != nil {
+		errs.add(&ValidationError{Field: "optionals.quantity", Path: "quantity", Value: q.String(), Rule: "quantity", Msg: err.Error()})
+	}
+	if optional.Request == nil {
+		return errs.err()
+	}
+	if optional.Request.Quantity.string != "" && (optional.Request.Count.int != 0 || optional.Request.Unit.string != "") {
+		errs.add(&ValidationError{Field: "optionals.request.quantity", Path: "request.quantity", Value: optional.Request.Quantity.string, Rule: "one-of", Msg: "optional " + name + " request takes either a count and unit, or a quantity, not both"})
+	} else if q, err := optional.request(); err != nil {
+		errs.add(&ValidationError{Field: "optionals.request.quantity", Path: "request.quantity", Value: optional.Request.Quantity.string, Rule: "quantity", Msg: err.Error()})
+	} else if err := checkQuantity(name, q); err != nil {
+		errs.add(&ValidationError{Field: "optionals.request.quantity", Path: "request.quantity", Value: q.String(), Rule: "quantity", Msg: err.Error()})
+	}
+	return errs.err()
+}
+
+func checkRequest(optional Optional) error {
+	/*
+		requests can only be given for cpu and memory, and a request may never exceed its limit. The two can use
+		different units, eg. a limit of 2 cpus and a request of 500m, so both are normalized before comparing.
+	*/
+	if optional.Request == nil {
+		return nil
+	}
+	name := optional.Name.string
+	if name != "cpu" && name != "memory" {
+		return &ValidationError{Field: "optionals.request", Path: "request", Value: name, Rule: "not-supported", Msg: "optional request is only supported for: cpu, memory"}
+	}
+	if name == "memory" && optional.Request.Quantity.string == "" && optional.Request.Unit.string == "" && optional.Unit.string == "" {
+		return &ValidationError{Field: "optionals.request.unit", Path: "request.unit", Rule: "unit-required", Msg: "invalid or missing unit for: memory request"}
+	}
+	limit, err := optional.limit()
+	if err != nil {
+		return &ValidationError{Field: "optionals.quantity", Path: "quantity", Value: optional.Quantity.string, Rule: "quantity", Msg: err.Error()}
+	}
+	request, err := optional.request()
+	if err != nil {
+		return &ValidationError{Field: "optionals.request.quantity", Path: "request.quantity", Value: optional.Request.Quantity.string, Rule: "quantity", Msg: err.Error()}
+	}
+	if request.cmp(limit) > 0 {
+		return &ValidationError{Field: "optionals.request", Path: "request", Value: request.String(), Rule: "max", Msg: name + " request " + request.String() + " must not exceed its limit " + limit.String()}
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes and validates a request under the default configuration, see Provisioner.Decode for
+// any other.
+func (input *Request) UnmarshalJSON(data []byte) error {
+	return input.decode(data, currentConfig())
+}
+
+func (input *Request) decode(data []byte, config *Config) error {
+	/*
+		requests come in more than one version, see version.go. Each is converted to a Request, which is what gets
+		validated, with the paths of any problems given in terms of the version that was sent.
+	*/
+	var env envelope
+	switch problems, err := decodeTypes(data, &env); {
+	case err != nil:
+		return err
+	case len(problems) > 0:
+		// without the version, there is no telling what the rest of the request means
+		return problems.err()
+	}
+
+	var r Request
+	var problems ValidationErrors
+	var err error
+	path := func(p string) string { return p }
+	switch env.APIVersion {
+	case "", APIVersionV1:
+		r, problems, err = decodeV1(data, config)
+	case APIVersionV2:
+		r, problems, err = decodeV2(data, config)
+		path = v2Path
+	default:
+		return &ValidationError{Field: "apiVersion", Path: "apiVersion", Value: env.APIVersion, Rule: "enum", Msg: "apiVersion " + env.APIVersion + " is not one of: " + strings.Join(apiVersions, ", ")}
+	}
+	if err != nil {
+		return err
+	}
+	problems.add(env.checkKind())
+
+	// decoding problems tend to cause validation problems with the same value, which are left out
+	reported := make(map[string]bool)
+	for _, problem := range problems {
+		reported[owner(problem.Path)] = true
+	}
+	switch err := r.validate(config).(type) {
+	case nil:
+	case *ValidationError, ValidationErrors:
+		var found ValidationErrors
+		found.add(err)
+		for _, problem := range found {
+			problem.Path = path(problem.Path)
+			if !reported[owner(problem.Path)] {
+				problems = append(problems, problem)
+			}
+		}
+	case *PolicyError:
+		// policy errors are only reported for requests that are otherwise valid
+		if len(problems) == 0 {
+			return err.withPaths(path)
+		}
+	default:
+		if len(problems) == 0 {
+			return err
+		}
+	}
+	if len(problems) > 0 {
+		return problems.err()
+	}
+	*input = r
+	return nil
+}
+
+// owner is the part of the request that path is within: the list entry, if any, eg. "optionals[1]" for
+// "optionals[1].unit"
+func owner(path string) string {
+	if i := strings.LastIndex(path, "]"); i >= 0 {
+		return path[:i+1]
+	}
+	return path
+}
+
+func decodeV1(data []byte, config *Config) (Request, ValidationErrors, error) {
+	/*
+
+		type Request struct {
+			ProjectName string     `json:"projectname"`
+			Environment string     `json:"environment"`
+			Optionals   []Optional `json:"optionals,omitempty"`
+			Deployers   []string   `json:"deployers,omitempty"`
+			AllowIngress []string  `json:"allowIngress,omitempty"`
+			Egress       []EgressDestination `json:"egress,omitempty"`
+			Size         string              `json:"size,omitempty"`
+			Override     *Override           `json:"override,omitempty"`
+		}
+
+	*/
+	type exctract struct {
+		ProjectName  string              `json:"projectname"`
+		Environment  string              `json:"environment"`
+		Optionals    []Optional          `json:"optionals,omitempty"`
+		Deployers    []string            `json:"deployers,omitempty"`
+		AllowIngress []string            `json:"allowIngress,omitempty"`
+		Egress       []EgressDestination `json:"egress,omitempty"`
+		Size         string              `json:"size,omitempty"`
+		Override     *Override           `json:"override,omitempty"`
+	}
+
+	ex := exctract{}
+
+	problems, err := decodeTypes(data, &ex)
+	if err != nil {
+		return Request{}, nil, err
+	}
+
+	r := Request{
+		ProjectName:  ex.ProjectName,
+		Environment:  ex.Environment,
+		Optionals:    ex.Optionals,
+		Deployers:    ex.Deployers,
+		AllowIngress: ex.AllowIngress,
+		Egress:       ex.Egress,
+		Size:         ex.Size,
+		Override:     ex.Override,
+	}
+	if !config.Lenient {
+		problems.add(checkStrict(data, r.Optionals))
+	}
+	return r, problems, nil
+}
+
+func (input *Request) validate(config *Config) error {
+	/*
+		checks (and normalizes) a request, collecting every problem rather than stopping at the first, so that
+		they can all be fixed in one go. The custom decoders above only check types, everything else happens here,
+		for decoded requests and those built in code alike.
+	*/
+	var errs ValidationErrors
+	for _, field := range []struct {
+		name  string
+		value string
+	}{{"projectname", input.ProjectName}, {"environment", input.Environment}} {
+		switch {
+		case field.value == "":
+			errs.add(&ValidationError{Field: field.name, Path: field.name, Rule: "required", Msg: "missing data"})
+		case strings.Contains(field.value, " "):
+			errs.add(&ValidationError{Field: field.name, Path: field.name, Value: field.value, Rule: "no-spaces", Msg: "data contains illegal spaces"})
+		case strings.Contains(field.value, "_"):
+			errs.add(&ValidationError{Field: field.name, Path: field.name, Value: field.value, Rule: "no-underscores", Msg: "data contains illegal underscores"})
+		}
+	}
+
+	// make all lowercase
+	input.ProjectName = strings.ToLower(input.ProjectName)
+	input.Environment = strings.ToLower(input.Environment)
+
+	errs.add(validateDeployers(input, config))
+	errs.add(validateAllowIngress(input))
+	errs.add(validateEgress(input))
+	errs.add(validateLabels(input))
+	if input.ProjectName != "" && !strings.ContainsAny(input.ProjectName, " _") {
+		errs.add(validateProjectName(input, config))
+	}
+	if input.Environment != "" {
+		errs.add(applyEnvironmentDefaults(input, config))
+	}
+	errs.add(validateOverride(input))
+	if len(errs) == 0 {
+		for _, role := range config.Groups.Roles {
+			if _, err := config.Groups.groupName(input.Environment, input.ProjectName, role); err != nil {
+				return &InternalError{Msg: err.Error()}
+			}
+		}
+	}
+
+	for i, optional := range input.Optionals {
+		if err := checkStorageClass(optional, config.StorageClasses); err != nil {
+			errs.add(ValidationErrors{err.(*ValidationError)}.at("optionals[" + strconv.Itoa(i) + "]"))
+		}
+	}
+	// check optionals for dependencies
+	errs.add(checkOptionals(input.Optionals))
+	if len(errs) > 0 {
+		return errs.err()
+	}
+	return applyPolicy(input, config)
+}
+
+// DecodeRequest decodes and validates a single request under the default configuration, see Provisioner.Decode.
+func DecodeRequest(data []byte) (Request, error) {
+	return defaultProvisioner().Decode(data)
+}
+
+// Decode decodes and validates a single request. Requests may be supplied as json or as yaml. Yaml is converted
+// to json first, so that both go through the same custom decoders above.
+func (p *Provisioner) Decode(data []byte) (Request, error) {
+	var input Request
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return input, &ValidationError{Msg: "missing data"}
+	}
+	if trimmed[0] != '{' && trimmed[0] != '[' {
+		converted, err := yamlToJSON(trimmed)
+		if err != nil {
+			return input, err
+		}
+		trimmed = converted
+	}
+	err := input.decode(trimmed, p.config)
+	return input, err
+}
+
+// NewOptional builds a validated optional for requests that are constructed in code rather than decoded.
+func NewOptional(name string, count int, unit string) (Optional, error) {
+	o := Optional{Name: oName{optionalName(name)}, Count: oCount{count}, Unit: oUnit{unit}}
+	if !validName(o.Name.string) {
+		return o, &ValidationError{Field: "optionals.name", Path: "name", Value: o.Name.string, Rule: "enum", Msg: "optional name entry is invalid: " + o.Name.string}
+	}
+	if unit != "" && !validUnit(unit) {
+		return o, &ValidationError{Field: "optionals.unit", Path: "unit", Value: unit, Rule: "enum", Msg: "optional unit entry is invalid: " + unit}
+	}
+	return o, nil
+}
@@ -0,0 +1,272 @@
+package provisioner
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+/*
+	JSON Schema (draft-07) for requests, of every version. The shape comes from the Go types themselves, by way of
+	their json tags, and the constraints from the same lists and rules that validation uses, including those of
+	the config: environments, sizes, deployers and storage classes.
+
+	Values that the decoder lower cases, such as environment or optional names, are listed in lower case only, so
+	the schema is a little stricter than the decoder there. A few rules can't be expressed in a schema at all, and
+	are only enforced by the decoder:
+
+	- a request must not exceed its limit, nor either of them the environment's ceilings
+	- what a quantity means for its resource, eg. that cpu can't use binary suffixes
+	- that the names generated from the projectname fit, and that optionals and egress destinations are unique
+*/
+
+const schemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// jsonSchema is the subset of JSON Schema that the request schema needs
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 interface{}            `json:"type,omitempty"` // a single type, or a list of them
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+	PatternProperties    map[string]*jsonSchema `json:"patternProperties,omitempty"`
+	PropertyNames        *jsonSchema            `json:"propertyNames,omitempty"`
+	Dependencies         map[string][]string    `json:"dependencies,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	MaxItems             *int                   `json:"maxItems,omitempty"`
+	UniqueItems          bool                   `json:"uniqueItems,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	MaxLength            *int                   `json:"maxLength,omitempty"`
+	Minimum              *int                   `json:"minimum,omitempty"`
+	AllOf                []*jsonSchema          `json:"allOf,omitempty"`
+	AnyOf                []*jsonSchema          `json:"anyOf,omitempty"`
+	OneOf                []*jsonSchema          `json:"oneOf,omitempty"`
+	Not                  *jsonSchema            `json:"not,omitempty"`
+	If                   *jsonSchema            `json:"if,omitempty"`
+	Then                 *jsonSchema            `json:"then,omitempty"`
+	Else                 *jsonSchema            `json:"else,omitempty"`
+}
+
+const (
+	// a DNS-1123 label, and a DNS-1123 subdomain that may end in a dot
+	labelPattern   = `^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	dnsNamePattern = `^[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?(\.[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?)*\.?$`
+	octetPattern   = `(25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])`
+	cidrPattern    = `^(` + octetPattern + `\.){3}` + octetPattern + `/(3[0-2]|[12]?[0-9])$`
+	// see parseQuantity, negative quantities are never valid
+	quantityPattern = `^\+?([0-9]+\.?[0-9]*|\.[0-9]+)([eE][+-]?[0-9]{1,2}|Ki|Mi|Gi|Ti|Pi|Ei|n|u|m|k|M|G|T|P|E)?$`
+)
+
+func intPointer(i int) *int {
+	return &i
+}
+
+func boolPointer(b bool) *bool {
+	return &b
+}
+
+func required(fields ...string) *jsonSchema {
+	return &jsonSchema{Required: fields}
+}
+
+func nameIn(names ...string) *jsonSchema {
+	return &jsonSchema{Properties: map[string]*jsonSchema{"name": &jsonSchema{Enum: names}}, Required: []string{"name"}}
+}
+
+// leaf types are those with their own decoders, whose json is a single value rather than an object
+var schemaLeaves = map[reflect.Type]func(lenient bool) *jsonSchema{
+	reflect.TypeOf(oName{}): func(bool) *jsonSchema {
+		names := optionalNames()
+		for alias := range optionalAliases {
+			names = append(names, alias)
+		}
+		return &jsonSchema{Type: "string", Enum: names, Description: "count/routes.route.openshift.io is the same as routes"}
+	},
+	reflect.TypeOf(oCount{}): func(lenient bool) *jsonSchema {
+		if lenient {
+			return &jsonSchema{Type: "integer", Minimum: intPointer(0)}
+		}
+		return &jsonSchema{Type: "integer", Minimum: intPointer(1)}
+	},
+	reflect.TypeOf(oUnit{}): func(bool) *jsonSchema {
+		return &jsonSchema{Type: "string", Enum: units}
+	},
+	reflect.TypeOf(oQuantity{}): func(bool) *jsonSchema {
+		return &jsonSchema{Type: []string{"string", "number"}, Pattern: quantityPattern, Minimum: intPointer(0)}
+	},
+}
+
+func typeSchema(t reflect.Type, lenient bool) *jsonSchema {
+	if leaf, ok := schemaLeaves[t]; ok {
+		return leaf(lenient)
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem(), lenient)
+	case reflect.Slice:
+		return &jsonSchema{Type: "array", Items: typeSchema(t.Elem(), lenient)}
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+	case reflect.Int:
+		return &jsonSchema{Type: "integer"}
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Map:
+		return &jsonSchema{Type: "object", PatternProperties: map[string]*jsonSchema{".*": typeSchema(t.Elem(), lenient)}}
+	case reflect.Struct:
+		s := &jsonSchema{Type: "object", Properties: make(map[string]*jsonSchema)}
+		if !lenient {
+			s.AdditionalProperties = boolPointer(false)
+		}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if field.PkgPath != "" || name == "-" || name == "" {
+				continue
+			}
+			s.Properties[name] = typeSchema(field.Type, lenient)
+		}
+		return s
+	}
+	// every field of the request types is covered above
+	panic("no schema for type " + t.String())
+}
+
+func requestSchema(config *Config) *jsonSchema {
+	// every version of the request, see version.go
+	v1 := v1Schema(config)
+	return &jsonSchema{
+		Schema:      schemaDraft,
+		Title:       "Project request",
+		Description: "A single project to be provisioned. Values that are lower cased when decoded are listed in lower case.",
+		OneOf:       []*jsonSchema{v1, v2Schema(config, v1)},
+	}
+}
+
+func v1Schema(config *Config) *jsonSchema {
+	s := typeSchema(reflect.TypeOf(Request{}), config.Lenient)
+	s.Title = "Project request, " + APIVersionV1
+	s.Required = []string{"projectname", "environment"}
+
+	properties := s.Properties
+	properties["apiVersion"] = &jsonSchema{Type: "string", Enum: []string{APIVersionV1}}
+	properties["kind"] = &jsonSchema{Type: "string", Enum: []string{KindProjectRequest}}
+	// see envelope.checkKind, only unversioned requests may leave out kind
+	s.Dependencies = map[string][]string{"apiVersion": []string{"kind"}}
+	properties["projectname"].Pattern = labelPattern
+	properties["projectname"].MaxLength = intPointer(maxLabelLength)
+	properties["environment"].Enum = config.environmentNames()
+	properties["deployers"].Items.Enum = config.deployerNames()
+	properties["deployers"].UniqueItems = true
+	if len(config.Deployers) == 0 {
+		properties["deployers"].MaxItems = intPointer(0)
+	}
+	properties["allowIngress"].Items.Enum = ingressPolicies
+	properties["allowIngress"].UniqueItems = true
+	properties["size"].Enum = config.sizeNames()
+	properties["override"].Required = []string{"approver"}
+	properties["override"].Properties["approver"].Pattern = `\S`
+
+	egress := properties["egress"]
+	egress.MaxItems = intPointer(maxEgressRules - 1)
+	egress.Items.Properties["cidrSelector"].Pattern = cidrPattern
+	egress.Items.Properties["dnsName"].Pattern = dnsNamePattern
+	egress.Items.Properties["dnsName"].MaxLength = intPointer(maxDNSNameLength + 1)
+	egress.Items.OneOf = []*jsonSchema{required("cidrSelector"), required("dnsName")}
+
+	optional := properties["optionals"].Items
+	optional.Required = []string{"name"}
+	countNames := append([]string(nil), objectCounts...)
+	for alias := range optionalAliases {
+		countNames = append(countNames, alias)
+	}
+	if len(config.StorageClasses) > 0 {
+		optional.Properties["storageClass"].Enum = config.StorageClasses
+	} else {
+		delete(optional.Properties, "storageClass")
+	}
+	request := optional.Properties["request"]
+	optional.AllOf = []*jsonSchema{
+		// a quantity, or a count and unit, never both
+		&jsonSchema{Not: required("quantity", "count")},
+		&jsonSchema{Not: required("quantity", "unit")},
+		// memory and storage are meaningless without a unit
+		&jsonSchema{If: nameIn("memory", "storage"), Then: &jsonSchema{AnyOf: []*jsonSchema{required("quantity"), required("unit")}}},
+		// object counts are just that
+		&jsonSchema{If: nameIn(countNames...), Then: &jsonSchema{Not: required("unit")}},
+		// requests are for cpu and memory only, and a memory request needs a unit of its own when the limit has none
+		&jsonSchema{If: nameIn("cpu", "memory"), Else: &jsonSchema{Not: required("request")}},
+		&jsonSchema{
+			If:   &jsonSchema{AllOf: []*jsonSchema{nameIn("memory"), &jsonSchema{Not: required("unit")}}},
+			Then: &jsonSchema{Properties: map[string]*jsonSchema{"request": &jsonSchema{AnyOf: []*jsonSchema{required("quantity"), required("unit")}}}},
+		},
+	}
+	request.AllOf = []*jsonSchema{&jsonSchema{Not: required("quantity", "count")}, &jsonSchema{Not: required("quantity", "unit")}}
+	if !config.Lenient {
+		// see strict.go, only object counts may be zero
+		for _, q := range []*jsonSchema{optional, request} {
+			q.AnyOf = []*jsonSchema{required("count"), required("quantity")}
+			q.Dependencies = map[string][]string{"unit": []string{"count"}}
+		}
+		optional.Properties["count"].Minimum = intPointer(0)
+		optional.AllOf = append(optional.AllOf, &jsonSchema{
+			If:   nameIn(countNames...),
+			Else: &jsonSchema{Properties: map[string]*jsonSchema{"count": &jsonSchema{Minimum: intPointer(1)}}},
+		})
+	}
+	return s
+}
+
+func v2Schema(config *Config, v1 *jsonSchema) *jsonSchema {
+	// the values are the same as in v1, only their place differs
+	s := typeSchema(reflect.TypeOf(RequestV2{}), config.Lenient)
+	s.Title = "Project request, " + APIVersionV2
+	s.Required = []string{"apiVersion", "kind", "metadata", "spec"}
+
+	properties := s.Properties
+	properties["apiVersion"].Enum = []string{APIVersionV2}
+	properties["kind"].Enum = []string{KindProjectRequest}
+
+	metadata := properties["metadata"]
+	metadata.Required = []string{"name"}
+	metadata.Properties["name"] = v1.Properties["projectname"]
+	labels := metadata.Properties["labels"]
+	labels.PropertyNames = &jsonSchema{Pattern: labelKeyPattern, MaxLength: intPointer(maxDNSNameLength + 1 + maxLabelLength)}
+	for _, value := range labels.PatternProperties {
+		value.Pattern = labelValuePattern
+	}
+
+	spec := properties["spec"]
+	spec.Required = []string{"environment"}
+	for _, name := range []string{"environment", "size", "deployers", "override"} {
+		spec.Properties[name] = v1.Properties[name]
+	}
+	network := spec.Properties["network"]
+	network.Properties["allowIngress"] = v1.Properties["allowIngress"]
+	network.Properties["egress"] = v1.Properties["egress"]
+
+	resource := spec.Properties["resources"].Items
+	resource.Required = []string{"name", "limit"}
+	resource.Properties["storageClass"] = v1.Properties["optionals"].Items.Properties["storageClass"]
+	if resource.Properties["storageClass"] == nil {
+		delete(resource.Properties, "storageClass")
+	}
+	resource.AllOf = []*jsonSchema{
+		&jsonSchema{If: nameIn("cpu", "memory"), Else: &jsonSchema{Not: required("request")}},
+	}
+	return s
+}
+
+// Schema returns the JSON Schema for requests under the default configuration.
+func Schema() []byte {
+	return defaultProvisioner().Schema()
+}
+
+// Schema returns the JSON Schema for requests under the provisioner's configuration.
+func (p *Provisioner) Schema() []byte {
+	b, _ := json.MarshalIndent(requestSchema(p.config), "", "  ")
+	return b
+}
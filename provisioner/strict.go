@@ -0,0 +1,131 @@
+package provisioner
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+	Strict decoding, the default. On top of the usual validation, decoded requests are rejected when they:
+
+	- contain a key that is not part of the API, including known keys in the wrong case, eg. "projectName"
+	- list the same optional twice (for storage and volumes: for the same storage class)
+	- give an optional, or its request, a count of zero or less, or neither a count nor a quantity. Object
+	  counts are the exception, a count of zero is how a request asks for none at all
+	- give a unit without a count
+
+	Older callers that relied on these being ignored can set lenient in the config, which turns all of them off.
+*/
+
+var (
+	requestFields  = []string{"apiVersion", "kind", "projectname", "environment", "optionals", "deployers", "allowIngress", "egress", "size", "override"}
+	optionalFields = []string{"name", "count", "unit", "quantity", "request", "storageClass"}
+	quantityFields = []string{"count", "unit", "quantity"}
+	egressFields   = []string{"cidrSelector", "dnsName"}
+	overrideFields = []string{"approver", "reason"}
+)
+
+func checkStrict(data []byte, optionals []Optional) error {
+	/*
+		data has already been decoded successfully, so only the keys are of interest here, not the values
+	*/
+	var errs ValidationErrors
+	var request map[string]json.RawMessage
+	json.Unmarshal(data, &request)
+	errs.add(checkFields(request, requestFields, ""))
+
+	var optionalData []json.RawMessage
+	json.Unmarshal(request["optionals"], &optionalData)
+	for i, raw := range optionalData {
+		path := "optionals[" + strconv.Itoa(i) + "]"
+		var optional map[string]json.RawMessage
+		json.Unmarshal(raw, &optional)
+		errs.add(checkFields(optional, optionalFields, path))
+		errs.add(checkCount(optional, path, i < len(optionals) && isObjectCount(optionals[i].Name.string)))
+		if optional["request"] != nil && string(optional["request"]) != "null" {
+			var request map[string]json.RawMessage
+			json.Unmarshal(optional["request"], &request)
+			errs.add(checkFields(request, quantityFields, path+".request"))
+			errs.add(checkCount(request, path+".request", false))
+		}
+	}
+
+	var egressData []json.RawMessage
+	json.Unmarshal(request["egress"], &egressData)
+	for i, raw := range egressData {
+		var egress map[string]json.RawMessage
+		json.Unmarshal(raw, &egress)
+		errs.add(checkFields(egress, egressFields, "egress["+strconv.Itoa(i)+"]"))
+	}
+
+	var override map[string]json.RawMessage
+	json.Unmarshal(request["override"], &override)
+	errs.add(checkFields(override, overrideFields, "override"))
+
+	errs.add(checkDuplicateOptionals(optionals))
+	return errs.err()
+}
+
+func checkFields(object map[string]json.RawMessage, fields []string, path string) error {
+	// keys are matched exactly, unlike encoding/json which ignores their case
+	var unknown []string
+	for key := range object {
+		if !inList(key, fields) {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	var errs ValidationErrors
+	for _, key := range unknown {
+		field := key
+		if path != "" {
+			field = path + "." + key
+		}
+		errs.add(&ValidationError{Path: field, Value: key, Rule: "unknown-field", Msg: "unknown field " + field + ", expected one of: " + strings.Join(fields, ", ")})
+	}
+	return errs.err()
+}
+
+func checkCount(object map[string]json.RawMessage, path string, zero bool) error {
+	// zero is whether a count of zero is allowed
+	count, hasCount := object["count"]
+	_, hasQuantity := object["quantity"]
+	_, hasUnit := object["unit"]
+	switch {
+	case hasCount:
+		var n int
+		if json.Unmarshal(count, &n) != nil {
+			break
+		}
+		if zero && n < 0 {
+			return &ValidationError{Field: "optionals.count", Path: path + ".count", Value: strconv.Itoa(n), Rule: "min", Msg: path + " count must not be negative, found: " + strconv.Itoa(n)}
+		}
+		if !zero && n <= 0 {
+			return &ValidationError{Field: "optionals.count", Path: path + ".count", Value: strconv.Itoa(n), Rule: "min", Msg: path + " count must be greater than zero, found: " + strconv.Itoa(n)}
+		}
+	case hasUnit:
+		return &ValidationError{Field: "optionals.unit", Path: path + ".unit", Rule: "misplaced-unit", Msg: path + " has a unit, but no count"}
+	case !hasQuantity:
+		return &ValidationError{Field: "optionals.count", Path: path + ".count", Rule: "required", Msg: path + " needs either a count or a quantity"}
+	}
+	return nil
+}
+
+func checkDuplicateOptionals(optionals []Optional) error {
+	var errs ValidationErrors
+	seen := make(map[string]bool)
+	for i, optional := range optionals {
+		key := optional.Name.string
+		if optional.StorageClass != "" {
+			key += " (" + strings.ToLower(optional.StorageClass) + ")"
+		}
+		if seen[key] {
+			errs.add(&ValidationError{Field: "optionals.name", Path: "optionals[" + strconv.Itoa(i) + "].name", Value: key, Rule: "unique", Msg: "optional is listed more than once: " + key})
+			continue
+		}
+		seen[key] = true
+	}
+	return errs.err()
+}
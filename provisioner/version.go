@@ -0,0 +1,221 @@
+package provisioner
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/*
+	Versions of the request API. A request names its version in apiVersion, next to its kind:
+
+	- v1 is the original shape, see request.go. Requests without an apiVersion are v1, which is what the
+	  Helpline form sends.
+	- v2 moves the project's name and labels into metadata, and everything else into spec. Resources are always
+	  given as quantities, with the request next to the limit, and the network settings are grouped together:
+
+		{
+			"apiVersion": "provisioner/v2",
+			"kind": "ProjectRequest",
+			"metadata": {"name": "boogie-test", "labels": {"team": "payments"}},
+			"spec": {
+				"environment": "dev",
+				"size": "small",
+				"resources": [
+					{"name": "cpu", "limit": "2", "request": "500m"},
+					{"name": "storage", "limit": "100Gi", "storageClass": "fast-ssd"}
+				],
+				"deployers": ["relman"],
+				"network": {"allowIngress": ["same-namespace"], "egress": [{"dnsName": "registry.example.com"}]}
+			}
+		}
+
+	Every version is converted to a Request, the one type that is validated and generated. Problems are reported
+	with the paths of the version that was sent, eg. "spec.resources[0].limit" rather than "optionals[0].quantity".
+*/
+
+const (
+	APIVersionV1       = "provisioner/v1"
+	APIVersionV2       = "provisioner/v2"
+	KindProjectRequest = "ProjectRequest"
+)
+
+var apiVersions = []string{APIVersionV1, APIVersionV2}
+
+type envelope struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+}
+
+func (env envelope) checkKind() error {
+	// unversioned requests predate kind, so only those may leave it out
+	switch {
+	case env.Kind == KindProjectRequest:
+	case env.Kind == "" && env.APIVersion == "":
+	case env.Kind == "":
+		return &ValidationError{Field: "kind", Path: "kind", Rule: "required", Msg: "kind must be " + KindProjectRequest}
+	default:
+		return &ValidationError{Field: "kind", Path: "kind", Value: env.Kind, Rule: "enum", Msg: "kind " + env.Kind + " is not one of: " + KindProjectRequest}
+	}
+	return nil
+}
+
+// RequestV2 is the v2 shape of a request. Use Convert to turn it into a Request.
+type RequestV2 struct {
+	APIVersion string     `json:"apiVersion"`
+	Kind       string     `json:"kind"`
+	Metadata   MetadataV2 `json:"metadata"`
+	Spec       SpecV2     `json:"spec"`
+}
+
+// MetadataV2 names the project, and holds the labels added to it.
+type MetadataV2 struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// SpecV2 holds everything about the project other than its name and labels.
+type SpecV2 struct {
+	Environment string       `json:"environment"`
+	Size        string       `json:"size,omitempty"`
+	Resources   []ResourceV2 `json:"resources,omitempty"`
+	Deployers   []string     `json:"deployers,omitempty"` // nil means the configured defaults, empty means none
+	Network     *NetworkV2   `json:"network,omitempty"`
+	Override    *Override    `json:"override,omitempty"`
+}
+
+// ResourceV2 is a single resource limit, the v2 counterpart of an Optional.
+type ResourceV2 struct {
+	Name         oName     `json:"name"`
+	Limit        oQuantity `json:"limit"`
+	Request      oQuantity `json:"request,omitempty"` // cpu and memory only
+	StorageClass string    `json:"storageClass,omitempty"`
+}
+
+// NetworkV2 holds the network policies of the project. Leaving it out is the same as leaving out both of its
+// fields.
+type NetworkV2 struct {
+	AllowIngress []string            `json:"allowIngress,omitempty"` // the standard allow policies to generate, none unless asked for
+	Egress       []EgressDestination `json:"egress,omitempty"`
+}
+
+var (
+	v2Fields       = []string{"apiVersion", "kind", "metadata", "spec"}
+	metadataFields = []string{"name", "labels"}
+	specFields     = []string{"environment", "size", "resources", "deployers", "network", "override"}
+	resourceFields = []string{"name", "limit", "request", "storageClass"}
+	networkFields  = []string{"allowIngress", "egress"}
+)
+
+// Convert returns the Request that r describes. It is not validated, that happens when it is generated.
+func (r RequestV2) Convert() Request {
+	input := Request{
+		ProjectName: r.Metadata.Name,
+		Environment: r.Spec.Environment,
+		Deployers:   r.Spec.Deployers,
+		Size:        r.Spec.Size,
+		Override:    r.Spec.Override,
+		Labels:      r.Metadata.Labels,
+	}
+	if r.Spec.Network != nil {
+		input.AllowIngress = r.Spec.Network.AllowIngress
+		input.Egress = r.Spec.Network.Egress
+	}
+	for _, resource := range r.Spec.Resources {
+		optional := Optional{Name: resource.Name, Quantity: resource.Limit, StorageClass: resource.StorageClass}
+		if resource.Request.string != "" {
+			optional.Request = &oRequest{Quantity: resource.Request}
+		}
+		input.Optionals = append(input.Optionals, optional)
+	}
+	return input
+}
+
+func decodeV2(data []byte, config *Config) (Request, ValidationErrors, error) {
+	var r RequestV2
+	problems, err := decodeTypes(data, &r)
+	if err != nil {
+		return Request{}, nil, err
+	}
+	input := r.Convert()
+
+	for i, resource := range r.Spec.Resources {
+		path := "spec.resources[" + strconv.Itoa(i) + "].limit"
+		if resource.Limit.string == "" && !problems.has(path) {
+			problems.add(&ValidationError{Field: "optionals.quantity", Path: path, Rule: "required", Msg: "resource " + resource.Name.string + " needs a limit"})
+		}
+	}
+	if !config.Lenient {
+		problems.add(checkStrictV2(data))
+		var duplicates ValidationErrors
+		duplicates.add(checkDuplicateOptionals(input.Optionals))
+		for _, duplicate := range duplicates {
+			duplicate.Path = v2Path(duplicate.Path)
+		}
+		problems = append(problems, duplicates...)
+	}
+	return input, problems, nil
+}
+
+func checkStrictV2(data []byte) error {
+	// see strict.go, the paths here are already those of v2
+	var errs ValidationErrors
+	var request map[string]json.RawMessage
+	json.Unmarshal(data, &request)
+	errs.add(checkFields(request, v2Fields, ""))
+
+	var metadata, spec, network, override map[string]json.RawMessage
+	json.Unmarshal(request["metadata"], &metadata)
+	errs.add(checkFields(metadata, metadataFields, "metadata"))
+	json.Unmarshal(request["spec"], &spec)
+	errs.add(checkFields(spec, specFields, "spec"))
+
+	var resources []map[string]json.RawMessage
+	json.Unmarshal(spec["resources"], &resources)
+	for i, resource := range resources {
+		errs.add(checkFields(resource, resourceFields, "spec.resources["+strconv.Itoa(i)+"]"))
+	}
+
+	json.Unmarshal(spec["network"], &network)
+	errs.add(checkFields(network, networkFields, "spec.network"))
+	var egress []map[string]json.RawMessage
+	json.Unmarshal(network["egress"], &egress)
+	for i, destination := range egress {
+		errs.add(checkFields(destination, egressFields, "spec.network.egress["+strconv.Itoa(i)+"]"))
+	}
+
+	json.Unmarshal(spec["override"], &override)
+	errs.add(checkFields(override, overrideFields, "spec.override"))
+	return errs.err()
+}
+
+var optionalPath = regexp.MustCompile(`^optionals(\[[0-9]+\])(\.request)?\.quantity$`)
+
+func v2Path(path string) string {
+	/*
+		translates the path of a problem with a Request into the path of the same value in a v2 request
+	*/
+	if m := optionalPath.FindStringSubmatch(path); m != nil {
+		if m[2] != "" {
+			return "spec.resources" + m[1] + ".request"
+		}
+		return "spec.resources" + m[1] + ".limit"
+	}
+	for _, p := range []struct{ v1, v2 string }{
+		{"projectname", "metadata.name"},
+		{"labels", "metadata.labels"},
+		{"optionals", "spec.resources"},
+		{"environment", "spec.environment"},
+		{"size", "spec.size"},
+		{"deployers", "spec.deployers"},
+		{"override", "spec.override"},
+		{"allowIngress", "spec.network.allowIngress"},
+		{"egress", "spec.network.egress"},
+	} {
+		if path == p.v1 || strings.HasPrefix(path, p.v1+".") || strings.HasPrefix(path, p.v1+"[") {
+			return p.v2 + strings.TrimPrefix(path, p.v1)
+		}
+	}
+	return path
+}
@@ -0,0 +1,541 @@
+package provisioner
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+/*
+	Minimal YAML serializer.
+
+	Rather than maintaining a second set of struct tags, objects are first serialized with encoding/json (so that
+	omitempty, key names like "limits.cpu" etc. are honoured exactly as they are for json output), and the resulting
+	json is then re-emitted as block style YAML. Keys keep the order in which encoding/json wrote them: struct field
+	order for structs, and sorted order for maps - so the output is stable between runs.
+*/
+
+const (
+	yamlScalar = iota
+	yamlMapping
+	yamlSequence
+)
+
+type yamlNode struct {
+	kind   int
+	value  string      // rendered scalar, only used when kind is yamlScalar
+	keys   []string    // mapping keys, in order
+	values []*yamlNode // mapping values (matching keys), or sequence items
+}
+
+func marshalYAML(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	node, err := readYAMLNode(decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	if node.kind == yamlScalar || len(node.values) == 0 {
+		b.WriteString(inlineYAML(node) + "\n")
+	} else {
+		writeYAMLNode(&b, node, 0)
+	}
+	return b.Bytes(), nil
+}
+
+func readYAMLNode(decoder *json.Decoder) (*yamlNode, error) {
+	// builds a tree from the json token stream, preserving the order of keys
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := token.(type) {
+	case json.Delim:
+		node := &yamlNode{kind: yamlSequence}
+		if t == '{' {
+			node.kind = yamlMapping
+		}
+		for decoder.More() {
+			if node.kind == yamlMapping {
+				key, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				node.keys = append(node.keys, key.(string))
+			}
+			child, err := readYAMLNode(decoder)
+			if err != nil {
+				return nil, err
+			}
+			node.values = append(node.values, child)
+		}
+		// consume the closing delimiter
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case string:
+		return &yamlNode{kind: yamlScalar, value: yamlString(t)}, nil
+	case json.Number:
+		return &yamlNode{kind: yamlScalar, value: t.String()}, nil
+	case bool:
+		return &yamlNode{kind: yamlScalar, value: strconv.FormatBool(t)}, nil
+	case nil:
+		return &yamlNode{kind: yamlScalar, value: "null"}, nil
+	default:
+		return nil, errors.New("unexpected json token while converting to yaml")
+	}
+}
+
+func inlineYAML(node *yamlNode) string {
+	// scalars and empty collections are the only things written on the same line as their key
+	switch node.kind {
+	case yamlMapping:
+		return "{}"
+	case yamlSequence:
+		return "[]"
+	default:
+		return node.value
+	}
+}
+
+func isBlockYAML(node *yamlNode) bool {
+	return node.kind != yamlScalar && len(node.values) > 0
+}
+
+func writeYAMLNode(b *bytes.Buffer, node *yamlNode, indent int) {
+	pad := strings.Repeat(" ", indent)
+
+	switch node.kind {
+	case yamlMapping:
+		for i, key := range node.keys {
+			writeYAMLEntry(b, pad+yamlString(key)+":", node.values[i], indent)
+		}
+	case yamlSequence:
+		for _, item := range node.values {
+			if item.kind == yamlMapping && len(item.values) > 0 {
+				// the first key goes on the same line as the dash, the rest line up underneath it
+				var nested bytes.Buffer
+				writeYAMLNode(&nested, item, indent+2)
+				b.WriteString(pad + "- " + strings.TrimPrefix(nested.String(), pad+"  "))
+				continue
+			}
+			writeYAMLEntry(b, pad+"-", item, indent+2)
+		}
+	}
+}
+
+func writeYAMLEntry(b *bytes.Buffer, prefix string, value *yamlNode, indent int) {
+	if !isBlockYAML(value) {
+		b.WriteString(prefix + " " + inlineYAML(value) + "\n")
+		return
+	}
+	b.WriteString(prefix + "\n")
+	if value.kind == yamlMapping {
+		indent += 2
+	}
+	// sequences are written at the same indentation as their key, like kubectl does
+	writeYAMLNode(b, value, indent)
+}
+
+func yamlString(s string) string {
+	/*
+		strings are written plain wherever that is unambiguous, and double quoted otherwise. A json encoded
+		string is always a valid double quoted YAML scalar.
+	*/
+	if !needsYAMLQuotes(s) {
+		return s
+	}
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func needsYAMLQuotes(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s {
+		return true
+	}
+	// values a YAML parser would read as something other than a string
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "y", "n", "on", "off", "null", "~":
+		return true
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return true
+	}
+	if _, err := strconv.ParseInt(s, 0, 64); err == nil {
+		return true
+	}
+	// indicator characters are not allowed at the start of a plain scalar
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") {
+		return true
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return true
+	}
+	for _, r := range s {
+		if r < ' ' || r == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+	Minimal YAML parser, used to accept YAML formatted requests.
+
+	It understands the subset of YAML that request documents need: block mappings and sequences (including lists
+	written at the same indentation as their key), plain, single and double quoted scalars, simple flow collections
+	like [a, b] or {a: b}, and comments. The parsed document is converted to json so that it goes through exactly
+	the same decoders (and therefore the same validation) as a json request.
+
+	Scalars follow the YAML 1.2 core schema: null, booleans and numbers are typed, everything else is a string.
+*/
+
+type yamlLine struct {
+	number  int
+	indent  int
+	content string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func yamlToJSON(data []byte) ([]byte, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(string(data), "\n") {
+		raw = strings.TrimRight(raw, " \t\r")
+		content := strings.TrimSpace(stripYAMLComment(raw))
+		if content == "" || (content == "---" && len(p.lines) == 0) {
+			continue
+		}
+		if content == "---" || content == "..." {
+			return nil, yamlError(i+1, "multiple documents are not supported")
+		}
+		if strings.HasPrefix(strings.TrimLeft(raw, " "), "\t") {
+			return nil, yamlError(i+1, "tabs are not allowed for indentation")
+		}
+		p.lines = append(p.lines, yamlLine{number: i + 1, indent: len(raw) - len(strings.TrimLeft(raw, " ")), content: content})
+	}
+	if len(p.lines) == 0 {
+		return nil, errors.New("yaml: empty document")
+	}
+
+	value, err := p.parseBlock(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, yamlError(p.lines[p.pos].number, "unexpected indentation")
+	}
+	return json.Marshal(value)
+}
+
+func yamlError(line int, msg string) error {
+	return errors.New("yaml: line " + strconv.Itoa(line) + ": " + msg)
+}
+
+func stripYAMLComment(line string) string {
+	// a '#' starts a comment at the start of a line or after whitespace, as long as it is not inside quotes
+	quote := rune(0)
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func isYAMLSequenceItem(content string) bool {
+	return content == "-" || strings.HasPrefix(content, "- ")
+}
+
+func splitYAMLKey(content string) (string, string, bool) {
+	// finds the "key: value" separator, ignoring any that appear inside a quoted key
+	start := 0
+	if strings.HasPrefix(content, "\"") || strings.HasPrefix(content, "'") {
+		end := strings.IndexByte(content[1:], content[0])
+		if end < 0 {
+			return "", "", false
+		}
+		start = end + 2
+	}
+	for i := start; i < len(content); i++ {
+		if content[i] == ':' && (i == len(content)-1 || content[i+1] == ' ') {
+			return strings.TrimSpace(content[:i]), strings.TrimSpace(content[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+func (p *yamlParser) parseBlock(indent int) (interface{}, error) {
+	line := p.lines[p.pos]
+	if isYAMLSequenceItem(line.content) {
+		return p.parseSequence(indent)
+	}
+	if _, _, ok := splitYAMLKey(line.content); ok && !strings.HasPrefix(line.content, "{") && !strings.HasPrefix(line.content, "[") {
+		return p.parseMapping(indent)
+	}
+	p.pos++
+	return parseYAMLValue(line.content, line.number)
+}
+
+func (p *yamlParser) parseMapping(indent int) (interface{}, error) {
+	mapping := make(map[string]interface{})
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent {
+		line := p.lines[p.pos]
+		if isYAMLSequenceItem(line.content) {
+			return nil, yamlError(line.number, "unexpected sequence item in mapping")
+		}
+		rawKey, rest, ok := splitYAMLKey(line.content)
+		if !ok {
+			return nil, yamlError(line.number, "expected a \"key: value\" pair")
+		}
+		key, err := parseYAMLScalar(rawKey, line.number)
+		if err != nil {
+			return nil, err
+		}
+		keyString, ok := key.(string)
+		if !ok {
+			keyString = rawKey
+		}
+		if _, exists := mapping[keyString]; exists {
+			return nil, yamlError(line.number, "duplicate key: "+keyString)
+		}
+		p.pos++
+
+		var value interface{}
+		switch {
+		case rest != "":
+			value, err = parseYAMLValue(rest, line.number)
+		case p.pos < len(p.lines) && p.lines[p.pos].indent > indent:
+			value, err = p.parseBlock(p.lines[p.pos].indent)
+		case p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLSequenceItem(p.lines[p.pos].content):
+			// lists are commonly written at the same indentation as their key
+			value, err = p.parseSequence(indent)
+		}
+		if err != nil {
+			return nil, err
+		}
+		mapping[keyString] = value
+	}
+	if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+		return nil, yamlError(p.lines[p.pos].number, "unexpected indentation")
+	}
+	return mapping, nil
+}
+
+func (p *yamlParser) parseSequence(indent int) (interface{}, error) {
+	sequence := []interface{}{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLSequenceItem(p.lines[p.pos].content) {
+		line := p.lines[p.pos]
+		item := strings.TrimSpace(strings.TrimPrefix(line.content, "-"))
+		if item == "" {
+			// the item is a nested block on the following lines, or null
+			p.pos++
+			var value interface{}
+			if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+				var err error
+				if value, err = p.parseBlock(p.lines[p.pos].indent); err != nil {
+					return nil, err
+				}
+			}
+			sequence = append(sequence, value)
+			continue
+		}
+		// treat the remainder of the line as if it started a block at its own column, eg. "- name: cpu"
+		p.lines[p.pos] = yamlLine{number: line.number, indent: indent + len(line.content) - len(item), content: item}
+		value, err := p.parseBlock(p.lines[p.pos].indent)
+		if err != nil {
+			return nil, err
+		}
+		sequence = append(sequence, value)
+	}
+	return sequence, nil
+}
+
+func parseYAMLValue(s string, line int) (interface{}, error) {
+	if strings.HasPrefix(s, "[") || strings.HasPrefix(s, "{") {
+		f := &yamlFlowParser{s: s, line: line}
+		value, err := f.parse()
+		if err != nil {
+			return nil, err
+		}
+		if f.skipSpace(); f.pos != len(f.s) {
+			return nil, yamlError(line, "unexpected characters after flow collection")
+		}
+		return value, nil
+	}
+	if strings.HasPrefix(s, "|") || strings.HasPrefix(s, ">") {
+		return nil, yamlError(line, "block scalars are not supported")
+	}
+	if strings.HasPrefix(s, "&") || strings.HasPrefix(s, "*") || strings.HasPrefix(s, "!") {
+		return nil, yamlError(line, "anchors, aliases and tags are not supported")
+	}
+	return parseYAMLScalar(s, line)
+}
+
+func parseYAMLScalar(s string, line int) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(s, "\""):
+		var value string
+		if err := json.Unmarshal([]byte(s), &value); err != nil {
+			return nil, yamlError(line, "invalid double quoted string: "+s)
+		}
+		return value, nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return nil, yamlError(line, "invalid single quoted string: "+s)
+		}
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	}
+
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	if isYAMLNumber(s) {
+		return json.Number(s), nil
+	}
+	return s, nil
+}
+
+func isYAMLNumber(s string) bool {
+	// only accept numbers that are also valid json numbers, anything else stays a string
+	var n json.Number
+	return json.Unmarshal([]byte(s), &n) == nil
+}
+
+/*
+	flow collections: [a, "b", {c: d}]
+*/
+
+type yamlFlowParser struct {
+	s    string
+	pos  int
+	line int
+}
+
+func (f *yamlFlowParser) skipSpace() {
+	for f.pos < len(f.s) && f.s[f.pos] == ' ' {
+		f.pos++
+	}
+}
+
+func (f *yamlFlowParser) parse() (interface{}, error) {
+	f.skipSpace()
+	if f.pos >= len(f.s) {
+		return nil, yamlError(f.line, "unterminated flow collection")
+	}
+	switch f.s[f.pos] {
+	case '[':
+		f.pos++
+		sequence := []interface{}{}
+		for {
+			f.skipSpace()
+			if f.pos < len(f.s) && f.s[f.pos] == ']' {
+				f.pos++
+				return sequence, nil
+			}
+			item, err := f.parse()
+			if err != nil {
+				return nil, err
+			}
+			sequence = append(sequence, item)
+			if err := f.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		f.pos++
+		mapping := make(map[string]interface{})
+		for {
+			f.skipSpace()
+			if f.pos < len(f.s) && f.s[f.pos] == '}' {
+				f.pos++
+				return mapping, nil
+			}
+			key, err := f.scalar(":")
+			if err != nil {
+				return nil, err
+			}
+			keyString, ok := key.(string)
+			if !ok {
+				return nil, yamlError(f.line, "flow mapping keys must be strings")
+			}
+			if f.pos >= len(f.s) || f.s[f.pos] != ':' {
+				return nil, yamlError(f.line, "expected ':' in flow mapping")
+			}
+			f.pos++
+			value, err := f.parse()
+			if err != nil {
+				return nil, err
+			}
+			mapping[keyString] = value
+			if err := f.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return f.scalar(",]}")
+	}
+}
+
+func (f *yamlFlowParser) separator(end byte) error {
+	f.skipSpace()
+	if f.pos < len(f.s) && f.s[f.pos] == ',' {
+		f.pos++
+		return nil
+	}
+	if f.pos < len(f.s) && f.s[f.pos] == end {
+		return nil
+	}
+	return yamlError(f.line, "expected ',' or '"+string(end)+"' in flow collection")
+}
+
+func (f *yamlFlowParser) scalar(terminators string) (interface{}, error) {
+	f.skipSpace()
+	start := f.pos
+	if f.pos < len(f.s) && (f.s[f.pos] == '"' || f.s[f.pos] == '\'') {
+		quote := f.s[f.pos]
+		f.pos++
+		for f.pos < len(f.s) && f.s[f.pos] != quote {
+			if quote == '"' && f.s[f.pos] == '\\' {
+				f.pos++
+			}
+			f.pos++
+		}
+		if f.pos >= len(f.s) {
+			return nil, yamlError(f.line, "unterminated quoted string")
+		}
+		f.pos++
+	} else {
+		for f.pos < len(f.s) && !strings.ContainsRune(terminators, rune(f.s[f.pos])) {
+			f.pos++
+		}
+	}
+	return parseYAMLScalar(strings.TrimSpace(f.s[start:f.pos]), f.line)
+}